@@ -2,10 +2,12 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"os"
 
+	"github.com/Quorum-Code/chirpy/internal/database"
 	"github.com/Quorum-Code/chirpy/internal/webserver"
 )
 
@@ -19,18 +21,72 @@ var HelpText = `
 'h' -> Help: prints out some helpful text
 `
 
+var UsageText = `usage:
+  chirpy [flags]                           run the server
+  chirpy migrate [-dry-run] [-path file]   upgrade a JSON database file
+
+flags:
+`
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), UsageText)
+		flag.PrintDefaults()
+	}
+	debug := flag.Bool("debug", false, "Enable debug mode")
+	testing := flag.Bool("testing", false, "Enable for testing")
+	storage := flag.String("storage", "json", "Storage backend: json or sqlite")
+	editWindow := flag.Duration("edit-window", 0, "How long chirps stay editable on the free plan, overriding its edit_window in -plans; other plans keep theirs. 0 to leave it to the plans")
+	trashRetention := flag.Duration("trash-retention", database.DefaultTrashRetention, "How long deleted chirps can be restored")
+	watch := flag.Bool("watch", false, "Reload the JSON database file when edited outside the server")
+	plansPath := flag.String("plans", "", "JSON file of Chirpy Red plans and Polka events, built-in plans when empty")
+	flag.Parse()
+
+	var plans *database.PlanConfig
+	if *plansPath != "" {
+		cfg, err := database.LoadPlanConfig(*plansPath)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+		plans = &cfg
+	}
+
 	// Pass Server Config
 	svrcfg := webserver.ServerConfig{
-		IsDebug:   *flag.Bool("debug", false, "Enable debug mode"),
-		IsTesting: *flag.Bool("testing", false, "Enable for testing"),
+		IsDebug:   *debug,
+		IsTesting: *testing,
+		Storage:   *storage,
+
+		WatchDatabase:  *watch,
+		Plans:          plans,
+		EditWindow:     *editWindow,
+		TrashRetention: *trashRetention,
 	}
 
 	// Start server
-	_ = webserver.StartServer(svrcfg)
+	server, closeServer := webserver.StartServer(svrcfg)
 
 	// Start cli reader
 	readCommandLine()
+
+	// Stop server, then flush the database once requests have finished
+	if server != nil {
+		err := server.Shutdown(context.Background())
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+		}
+
+		err = closeServer()
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			os.Exit(1)
+		}
+	}
 }
 
 func readCommandLine() {
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+	"github.com/Quorum-Code/chirpy/internal/webserver"
+)
+
+// runMigrate upgrades a JSON database file to the current schema version,
+// returning the process exit code
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "Print the changes without writing them")
+	path := flags.String("path", "", "Database file, defaults to ~/.chirpy/database.json")
+	flags.Parse(args)
+
+	if *path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+			return 1
+		}
+		*path = filepath.Join(home, webserver.ChirpyFolder, webserver.DatabaseFile)
+	}
+
+	result, err := database.MigrateFile(*path, *dryRun)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return 1
+	}
+
+	if len(result.Applied) == 0 {
+		fmt.Printf("%s is already at version %d\n", *path, result.To)
+		return 0
+	}
+
+	fmt.Printf("%s: version %d -> %d\n", *path, result.From, result.To)
+	for _, m := range result.Applied {
+		fmt.Printf("  %s\n", m)
+	}
+	fmt.Println()
+	for _, line := range result.Changes {
+		fmt.Println(line)
+	}
+
+	if *dryRun {
+		fmt.Println("\ndry run, nothing written")
+	} else {
+		fmt.Printf("\nmigrated, previous file kept as %s.v%d.bak\n", *path, result.From)
+	}
+
+	return 0
+}
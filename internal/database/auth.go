@@ -101,17 +101,17 @@ func (db *DB) IsPolkaKey(key string) bool {
 }
 
 func (db *DB) ValidLogin(email string, pass string) (User, bool) {
+	db.mux.RLock()
 	user, ok := db.getUserByEmail(email)
-	if !ok {
-		return User{}, false
-	}
+	hash, hok := db.database.Hashes[user.Id]
+	db.mux.RUnlock()
 
-	_, ok = db.database.Hashes[user.Id]
-	if !ok {
+	if !ok || !hok {
 		return User{}, false
 	}
 
-	err := bcrypt.CompareHashAndPassword(db.database.Hashes[user.Id], []byte(pass))
+	// Compare outside the lock, bcrypt is slow on purpose
+	err := bcrypt.CompareHashAndPassword(hash, []byte(pass))
 	if err != nil {
 		return User{}, false
 	} else {
@@ -123,10 +123,6 @@ func (db *DB) IsEmailUsed(email string) bool {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 
-	for _, user := range db.database.Users {
-		if user.Email == email {
-			return true
-		}
-	}
-	return false
+	_, ok := db.index.usersByEmail[emailKey(email)]
+	return ok
 }
@@ -0,0 +1,115 @@
+package database
+
+import "errors"
+
+var ErrBlockSelf error = errors.New("users can't block or mute themselves")
+var ErrBlocked error = errors.New("a block between these users prevents this")
+
+// Block hides blockerID and userID from each other and ends any follows
+// between them, blocking twice is a no-op
+func (db *DB) Block(blockerID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	err := db.checkTarget(blockerID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.addRelation(RelationBlock, blockerID, userID)
+	if err != nil {
+		return err
+	}
+	_, err = db.removeRelation(RelationFollow, blockerID, userID)
+	if err != nil {
+		return err
+	}
+	_, err = db.removeRelation(RelationFollow, userID, blockerID)
+	return err
+}
+
+// Unblock lifts a block, follows ended by it stay ended
+func (db *DB) Unblock(blockerID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	err := db.checkTarget(blockerID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.removeRelation(RelationBlock, blockerID, userID)
+	return err
+}
+
+// Mute hides the chirps of userID from the lists muterID reads, without
+// userID knowing
+func (db *DB) Mute(muterID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	err := db.checkTarget(muterID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.addRelation(RelationMute, muterID, userID)
+	return err
+}
+
+func (db *DB) Unmute(muterID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	err := db.checkTarget(muterID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.removeRelation(RelationMute, muterID, userID)
+	return err
+}
+
+// GetBlocks pages through the users userID blocks by user id
+func (db *DB) GetBlocks(userID int, q ChirpQuery) (UserPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.pageUsers(db.index.relationsFrom.get(RelationBlock, userID), q), nil
+}
+
+// GetMutes pages through the users userID mutes by user id
+func (db *DB) GetMutes(userID int, q ChirpQuery) (UserPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.pageUsers(db.index.relationsFrom.get(RelationMute, userID), q), nil
+}
+
+// checkTarget checks that userID can be blocked or muted by fromID, must
+// hold the lock
+func (db *DB) checkTarget(fromID int, userID int) error {
+	if fromID == userID {
+		return ErrBlockSelf
+	}
+	if _, ok := db.database.Users[userID]; !ok {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// blocked reports whether either user blocks the other, must hold the lock
+func (db *DB) blocked(a int, b int) bool {
+	return db.hasRelation(RelationBlock, a, b) || db.hasRelation(RelationBlock, b, a)
+}
+
+// checkMentions rejects a body by authorID mentioning someone who blocks
+// them, must hold the lock
+func (db *DB) checkMentions(authorID int, body string) error {
+	for _, id := range db.mentionedUsers(body) {
+		if db.hasRelation(RelationBlock, id, authorID) {
+			return ErrBlocked
+		}
+	}
+	return nil
+}
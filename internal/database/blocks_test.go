@@ -0,0 +1,96 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBlockHidesBothWays(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+	carol, _ := db.CreateUserWith(UserParams{Email: "c@x.y", Password: "pw", Handle: "carol"})
+
+	db.Follow(bob.Id, alice.Id)
+	hello, _ := db.CreateChirp(alice.Id, "hello world")
+	db.CreateChirp(bob.Id, "hello back")
+
+	err := db.Block(alice.Id, bob.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Follows end and can't start again
+	following, _ := db.GetFollowing(bob.Id, ChirpQuery{})
+	if len(following.Users) != 0 {
+		t.Errorf("expected block to end follows, got %v", following.Users)
+	}
+	if err := db.Follow(bob.Id, alice.Id); !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected ErrBlocked, got %v", err)
+	}
+
+	for _, viewer := range []int{alice.Id, bob.Id} {
+		page, _ := db.GetChirpsPage(ChirpQuery{ViewerID: viewer})
+		if len(page.Chirps) != 1 || page.Chirps[0].AuthorId != viewer {
+			t.Errorf("viewer %d: expected only their own chirp, got %v", viewer, page.Chirps)
+		}
+		found, _ := db.SearchChirps("hello", ChirpQuery{ViewerID: viewer})
+		if len(found.Chirps) != 1 {
+			t.Errorf("viewer %d: expected one search result, got %v", viewer, found.Chirps)
+		}
+	}
+
+	if _, err := db.GetVisibleChirp(hello.Id, bob.Id); !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("expected ErrChirpNotFound, got %v", err)
+	}
+	if _, err := db.GetThread(hello.Id, ChirpQuery{}, 0, bob.Id); !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("expected thread to be hidden, got %v", err)
+	}
+	if _, err := db.GetVisibleChirp(hello.Id, carol.Id); err != nil {
+		t.Errorf("expected others to still see the chirp, got %v", err)
+	}
+
+	// Replies and mentions from the blocked user are rejected
+	_, err = db.CreateChirpWith(bob.Id, ChirpParams{Body: "hey", InReplyTo: hello.Id})
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected reply to be rejected, got %v", err)
+	}
+	_, err = db.CreateChirp(bob.Id, "hey @alice")
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("expected mention to be rejected, got %v", err)
+	}
+}
+
+func TestMuteHidesFromMuterOnly(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+
+	db.Follow(alice.Id, bob.Id)
+	chirp, _ := db.CreateChirp(bob.Id, "loud")
+	db.Mute(alice.Id, bob.Id)
+
+	timeline, _ := db.GetTimeline(alice.Id, ChirpQuery{})
+	if len(timeline.Chirps) != 0 {
+		t.Errorf("expected muted chirps out of the timeline, got %v", timeline.Chirps)
+	}
+	page, _ := db.GetChirpsPage(ChirpQuery{ViewerID: alice.Id})
+	if len(page.Chirps) != 0 {
+		t.Errorf("expected muted chirps out of the feed, got %v", page.Chirps)
+	}
+
+	// Muted chirps still open directly, and others see them as before
+	if _, err := db.GetVisibleChirp(chirp.Id, alice.Id); err != nil {
+		t.Errorf("expected muted chirp to open, got %v", err)
+	}
+	page, _ = db.GetChirpsPage(ChirpQuery{ViewerID: bob.Id})
+	if len(page.Chirps) != 1 {
+		t.Errorf("expected the muted user to see their chirp, got %v", page.Chirps)
+	}
+
+	db.Unmute(alice.Id, bob.Id)
+	timeline, _ = db.GetTimeline(alice.Id, ChirpQuery{})
+	if len(timeline.Chirps) != 1 {
+		t.Errorf("expected chirp back after unmute, got %v", timeline.Chirps)
+	}
+}
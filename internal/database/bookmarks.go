@@ -0,0 +1,29 @@
+package database
+
+// Bookmark saves a chirp for userID to find again, only they see their
+// bookmarks. Bookmarking twice is a no-op.
+func (db *DB) Bookmark(userID int, chirpID int) (ChirpView, error) {
+	return db.setChirpRelation(RelationBookmark, userID, chirpID, true)
+}
+
+func (db *DB) Unbookmark(userID int, chirpID int) (ChirpView, error) {
+	return db.setChirpRelation(RelationBookmark, userID, chirpID, false)
+}
+
+// GetBookmarks pages through the chirps userID bookmarked by chirp id.
+// Bookmarks of chirps in the trash or since hidden from them are left out.
+func (db *DB) GetBookmarks(userID int, q ChirpQuery) (ChirpPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	q.ViewerID = userID
+	ids := db.index.relationsFrom.get(RelationBookmark, userID)
+	live := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if db.database.Chirps[id].DeletedAt == nil {
+			live = append(live, id)
+		}
+	}
+
+	return db.pageChirps(live, q), nil
+}
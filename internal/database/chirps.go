@@ -3,16 +3,51 @@ package database
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
-	"sort"
 	"strconv"
+	"time"
 )
 
+// Longest chirp body on the free plan
+const MaxChirpLength = 140
+
+var ErrChirpTooLong error = errors.New("chirp is too long")
+var ErrReplyTargetNotFound error = errors.New("chirp being replied to not found")
+var ErrQuoteTargetNotFound error = errors.New("chirp being quoted not found")
+
 type Chirp struct {
-	Body     string `json:"body"`
-	Id       int    `json:"id"`
-	AuthorId int    `json:"author_id"`
+	Body      string    `json:"body"`
+	Id        int       `json:"id"`
+	AuthorId  int       `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Edited    bool      `json:"edited"`
+
+	// Id of the chirp this replies to, 0 when it starts a conversation
+	InReplyTo int `json:"in_reply_to,omitempty"`
+
+	// Id of the chirp this quotes, 0 when it quotes nothing
+	QuoteOf int `json:"quote_of,omitempty"`
+
+	// Images shown with the chirp, as many as the author's plan allows
+	Media []Attachment `json:"media,omitempty"`
+
+	// Poll people can vote in, nil when there is none
+	Poll *Poll `json:"poll,omitempty"`
+
+	// Who can read the chirp, one of the Visibility values
+	Visibility string `json:"visibility"`
+
+	// Users mentioned when a direct chirp was posted, its only readers
+	// besides the author
+	Recipients []int `json:"recipients,omitempty"`
+
+	// Users the body mentioned when it was written, by id, so mentions
+	// follow users across handle changes
+	Mentions []int `json:"mentions,omitempty"`
+
+	// Set while the chirp is in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 func (db *DB) UserPutChirp(req *http.Request, chirpID int) error {
@@ -49,15 +84,8 @@ func (db *DB) UserPutChirp(req *http.Request, chirpID int) error {
 	}
 
 	// Write to database
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
-	chirp.Body = b.ChirpBody
-	db.database.Chirps[chirp.Id] = chirp
-
-	go db.writeDB()
-
-	return nil
+	_, err = db.EditChirp(chirp.Id, authID, b.ChirpBody)
+	return err
 }
 
 func (db *DB) UserDeleteChirp(req *http.Request, chirpID int) error {
@@ -83,24 +111,55 @@ func (db *DB) UserDeleteChirp(req *http.Request, chirpID int) error {
 	}
 
 	// Delete chirp
-	db.DeleteChirp(chirpID)
-
-	return nil
+	return db.DeleteChirp(chirpID)
 }
 
-func (db *DB) DeleteChirp(cid int) {
+// DeleteChirp moves a chirp to the trash, leaving a tombstone until it is
+// purged after the trash retention
+func (db *DB) DeleteChirp(cid int) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
-	delete(db.database.Chirps, cid)
-	go db.writeDB()
+	chirp, ok := db.database.Chirps[cid]
+	if !ok {
+		return ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return ErrChirpDeleted
+	}
+
+	// Restoring the chirp doesn't pin it again
+	err := db.removeRelationsTo(RelationPin, cid)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	chirp.DeletedAt = &now
+	db.database.Chirps[cid] = chirp
+	db.index.trashChirp(chirp)
+
+	return db.store.SaveChirp(chirp)
+}
+
+// ChirpParams are the optional parts of a new chirp
+type ChirpParams struct {
+	Body      string
+	InReplyTo int
+	QuoteOf   int
+	Media     []AttachmentParams
+	Poll      *Poll
+
+	// Empty for public
+	Visibility string
 }
 
 func (db *DB) CreateChirp(id int, body string) (Chirp, error) {
-	if len(body) > 140 {
-		return Chirp{}, errors.New("chirp is too long")
-	}
+	return db.CreateChirpWith(id, ChirpParams{Body: body})
+}
 
+// CreateChirpWith validates and saves a new chirp by author id
+func (db *DB) CreateChirpWith(id int, params ChirpParams) (Chirp, error) {
 	if db.database.Chirps == nil {
 		return Chirp{}, errors.New("chirps map is nil")
 	}
@@ -108,61 +167,115 @@ func (db *DB) CreateChirp(id int, body string) (Chirp, error) {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
-	chirp := Chirp{Body: body, Id: db.database.NextCID, AuthorId: id}
+	return db.createChirp(id, params)
+}
+
+// createChirp validates and saves a new chirp, must hold the write lock
+func (db *DB) createChirp(id int, params ChirpParams) (Chirp, error) {
+	chirp, err := db.checkChirp(id, params)
+	if err != nil {
+		return Chirp{}, err
+	}
+	err = db.checkRate(id)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	now := time.Now().UTC()
+	chirp.Id = db.database.NextCID
+	chirp.CreatedAt = now
+	chirp.UpdatedAt = now
 	db.database.NextCID++
 	db.database.Chirps[chirp.Id] = chirp
+	db.index.addChirp(chirp)
+	db.index.addReply(chirp)
+	db.index.addAttachments(chirp)
 
-	go db.writeDB()
+	err = db.store.SaveChirp(chirp)
+	if err != nil {
+		return Chirp{}, err
+	}
 
 	return chirp, nil
 }
 
-func (db *DB) GetChirpsByUserID(id int) ([]Chirp, error) {
-	err := db.loadDB()
-	if err != nil {
-		return nil, err
+// checkChirp validates a new chirp by author id, returning the chirp to
+// save without its id and times. Must hold the lock.
+func (db *DB) checkChirp(id int, params ChirpParams) (Chirp, error) {
+	plan := db.entitlements(id)
+	if len(params.Body) > plan.MaxChirpLength {
+		return Chirp{}, ErrChirpTooLong
 	}
 
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
-	keys := []int{}
-	for k := range db.database.Chirps {
-		chirp := db.database.Chirps[k]
-		if chirp.AuthorId == id {
-			keys = append(keys, k)
+	// Replies must start from a chirp that is still up and the author can see
+	filter := db.filterFor(id)
+	if params.InReplyTo != 0 {
+		parent, ok := db.database.Chirps[params.InReplyTo]
+		if !ok || parent.DeletedAt != nil || !filter.inAudience(parent) {
+			return Chirp{}, ErrReplyTargetNotFound
+		}
+		if db.blocked(parent.AuthorId, id) {
+			return Chirp{}, ErrBlocked
+		}
+	}
+	if params.QuoteOf != 0 {
+		quoted, ok := db.database.Chirps[params.QuoteOf]
+		if !ok || quoted.DeletedAt != nil || !filter.inAudience(quoted) {
+			return Chirp{}, ErrQuoteTargetNotFound
 		}
 	}
 
-	sort.Ints(keys)
-	chirps := []Chirp{}
-	for v := range keys {
-		chirps = append(chirps, db.database.Chirps[keys[v]])
+	err := db.checkMentions(id, params.Body)
+	if err != nil {
+		return Chirp{}, err
 	}
 
-	return chirps, nil
-}
+	visibility, recipients, err := db.audience(id, params)
+	if err != nil {
+		return Chirp{}, err
+	}
+	media, err := db.attachments(id, params.Media, plan.MaxChirpMedia)
+	if err != nil {
+		return Chirp{}, err
+	}
+	poll, err := newPoll(params.Poll)
+	if err != nil {
+		return Chirp{}, err
+	}
 
-func (db *DB) GetChirps() ([]Chirp, error) {
-	err := db.loadDB()
+	return Chirp{
+		Body:       params.Body,
+		AuthorId:   id,
+		InReplyTo:  params.InReplyTo,
+		QuoteOf:    params.QuoteOf,
+		Media:      media,
+		Poll:       poll,
+		Visibility: visibility,
+		Recipients: recipients,
+		Mentions:   db.mentionedUsers(params.Body),
+	}, nil
+}
 
-	db.mux.Lock()
-	defer db.mux.Unlock()
+func (db *DB) GetChirpsByUserID(id int) ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
 
-	if err != nil {
-		return nil, err
+	ids := db.index.chirpsByAuthor[id]
+	chirps := make([]Chirp, 0, len(ids))
+	for _, cid := range ids {
+		chirps = append(chirps, db.database.Chirps[cid])
 	}
 
-	keys := []int{}
-	for k := range db.database.Chirps {
-		keys = append(keys, k)
-	}
+	return chirps, nil
+}
 
-	sort.Ints(keys)
+func (db *DB) GetChirps() ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
 
-	chirps := []Chirp{}
-	for v := range keys {
-		chirps = append(chirps, db.database.Chirps[keys[v]])
+	chirps := make([]Chirp, 0, len(db.index.chirpIDs))
+	for _, cid := range db.index.chirpIDs {
+		chirps = append(chirps, db.database.Chirps[cid])
 	}
 
 	return chirps, nil
@@ -178,10 +291,11 @@ func (db *DB) GetChirp(id int) (Chirp, error) {
 
 	chirp, ok := db.database.Chirps[id]
 	if !ok {
-		fmt.Printf("OK: %t\n", ok)
-		fmt.Println(db.database.Chirps)
 		return Chirp{}, ErrChirpNotFound
 	}
+	if chirp.DeletedAt != nil {
+		return chirp, ErrChirpDeleted
+	}
 
 	return chirp, nil
 }
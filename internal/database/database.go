@@ -1,82 +1,137 @@
 package database
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"sync"
+	"time"
 )
 
 type DB struct {
-	path     string
 	database *Database
+	index    *indexes
+	store    Store
 	mux      *sync.RWMutex
 
+	trashRetention time.Duration
+
+	// Plan name -> plan, and Polka event -> plan name
+	plans       map[string]Plan
+	polkaEvents map[string]string
+
 	JWT_SECRET  string
 	polkaApiKey string
 }
 
 type Database struct {
+	Version       int             `json:"version"`
 	NextUID       int             `json:"nextuid"`
 	NextCID       int             `json:"nextcid"`
+	NextMID       int             `json:"nextmid"`
+	NextDID       int             `json:"nextdid"`
+	NextLID       int             `json:"nextlid"`
 	Chirps        map[int]Chirp   `json:"chirps"`
 	Users         map[int]User    `json:"users"`
 	RefreshTokens map[string]bool `json:"refresh_tokens"`
 	Hashes        map[int][]byte  `json:"hashes"`
+
+	// Chirp id -> revisions, oldest first
+	Revisions map[int][]Revision `json:"revisions"`
+
+	// Follows and other edges between users and chirps, by Relation key
+	Relations map[string]Relation `json:"relations"`
+
+	// Uploaded images by id
+	Media map[int]Media `json:"media"`
+
+	// Unpublished and scheduled chirps by id
+	Drafts map[int]Draft `json:"drafts"`
+
+	// User curated lists by id, their members are relations
+	Lists map[int]List `json:"lists"`
 }
 
 var ErrChirpNotFound error = errors.New("chirp not found")
+var ErrChirpDeleted error = errors.New("chirp was deleted")
 var ErrUserNotFound error = errors.New("user not found")
 
 type User struct {
 	Email       string `json:"email"`
 	Id          int    `json:"id"`
 	IsChirpyRed bool   `json:"is_chirpy_red"`
+
+	// Name of the user's plan, kept in step with IsChirpyRed
+	Plan string `json:"plan"`
+
+	// Public profile, the handle is unique ignoring case
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
 }
 
-// Initialize db from io.Reader
-func InitDB(reader io.Reader, writepath string) (*DB, error) {
-	database := &Database{
+func newDatabase() *Database {
+	return &Database{
+		Version:       SchemaVersion,
 		Chirps:        make(map[int]Chirp),
 		NextCID:       1,
 		Users:         make(map[int]User),
 		NextUID:       1,
 		Hashes:        make(map[int][]byte),
 		RefreshTokens: make(map[string]bool),
+		Revisions:     make(map[int][]Revision),
+		Relations:     make(map[string]Relation),
+		Media:         make(map[int]Media),
+		NextMID:       1,
+		Drafts:        make(map[int]Draft),
+		NextDID:       1,
+		Lists:         make(map[int]List),
+		NextLID:       1,
 	}
+}
 
-	db := DB{
-		database:    database,
-		mux:         &sync.RWMutex{},
-		path:        writepath,
-		polkaApiKey: os.Getenv("POLKA_SECRET"),
-		JWT_SECRET:  os.Getenv("JWT_SECRET"),
-	}
+// Initialize db from io.Reader, persisting to a JSON file at writepath
+func InitDB(reader io.Reader, writepath string) (*DB, error) {
+	mux := &sync.RWMutex{}
+	return initStoreDB(newJSONStore(writepath, mux), mux)
+}
 
-	err := db.loadDB()
+// Initialize db from an SQLite file, creating it if needed
+func InitSQLiteDB(path string) (*DB, error) {
+	store, err := newSQLiteStore(path)
 	if err != nil {
 		return nil, err
 	}
+	return initStoreDB(store, &sync.RWMutex{})
+}
 
-	return &db, nil
+// Initialize db with the backend named by storage
+func InitStorageDB(storage string, path string) (*DB, error) {
+	switch storage {
+	case StorageJSON, "":
+		return InitDB(nil, path)
+	case StorageSQLite:
+		return InitSQLiteDB(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", storage)
+	}
 }
 
 // Initialize empty db
 func InitCleanDB() *DB {
-	database := &Database{
-		Chirps:        make(map[int]Chirp),
-		NextCID:       1,
-		Users:         make(map[int]User),
-		NextUID:       1,
-		Hashes:        make(map[int][]byte),
-		RefreshTokens: make(map[string]bool),
-	}
-
+	database := newDatabase()
+	plans := DefaultPlanConfig()
 	db := DB{
-		database:    database,
-		mux:         &sync.RWMutex{},
-		path:        "",
+		database:       database,
+		index:          buildIndexes(database),
+		store:          nopStore{},
+		mux:            &sync.RWMutex{},
+		trashRetention: DefaultTrashRetention,
+		plans:          plans.Plans,
+		polkaEvents:    plans.PolkaEvents,
+
 		polkaApiKey: os.Getenv("POLKA_SECRET"),
 		JWT_SECRET:  os.Getenv("JWT_SECRET"),
 	}
@@ -84,35 +139,66 @@ func InitCleanDB() *DB {
 	return &db
 }
 
-func (db *DB) loadDB() error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+func initStoreDB(store Store, mux *sync.RWMutex) (*DB, error) {
+	plans := DefaultPlanConfig()
+	db := DB{
+		store:          store,
+		mux:            mux,
+		trashRetention: DefaultTrashRetention,
+		plans:          plans.Plans,
+		polkaEvents:    plans.PolkaEvents,
 
-	dat, err := os.ReadFile(db.path)
-	if err != nil {
-		return err
+		polkaApiKey: os.Getenv("POLKA_SECRET"),
+		JWT_SECRET:  os.Getenv("JWT_SECRET"),
 	}
 
-	database := Database{}
-	err = json.Unmarshal(dat, &database)
+	err := db.loadDB()
 	if err != nil {
-		return err
+		store.Close()
+		return nil, err
 	}
-	db.database = &database
+
+	return &db, nil
+}
+
+// watcher is implemented by stores whose files can be edited by other programs
+type watcher interface {
+	watch(interval time.Duration, onReload func(*Database))
+}
+
+// Watch reloads the database when its file is edited outside the server,
+// checking every interval. Mutations not yet snapshotted are kept on top of
+// the edits. Only the JSON store can be watched.
+func (db *DB) Watch(interval time.Duration) error {
+	w, ok := db.store.(watcher)
+	if !ok {
+		return errors.New("storage backend can't watch for external edits")
+	}
+
+	// Runs with the write lock held
+	w.watch(interval, func(database *Database) {
+		db.database = database
+		db.index = buildIndexes(database)
+	})
 
 	return nil
 }
 
-func (db *DB) writeDB() error {
-	db.mux.RLock()
-	defer db.mux.RUnlock()
+// Close flushes and releases the underlying store
+func (db *DB) Close() error {
+	return db.store.Close()
+}
+
+func (db *DB) loadDB() error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
 
-	dat, err := json.MarshalIndent(db.database, "", "  ")
+	database, err := db.store.Load()
 	if err != nil {
 		return err
 	}
-
-	os.WriteFile(db.path, dat, 0777)
+	db.database = database
+	db.index = buildIndexes(database)
 
 	return nil
 }
@@ -0,0 +1,256 @@
+package database
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Furthest ahead a chirp can be scheduled
+const MaxScheduleAhead = 365 * 24 * time.Hour
+
+var ErrDraftNotFound error = errors.New("draft not found")
+var ErrBadPublishAt error = errors.New("publish_at must be in the future and within a year")
+
+// Draft is an unpublished chirp only its author sees. With PublishAt set it
+// is scheduled and the scheduler publishes it once that time comes.
+type Draft struct {
+	Id        int       `json:"id"`
+	AuthorId  int       `json:"author_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	DraftParams
+
+	// Why the last attempt to publish failed, the draft is unscheduled
+	PublishError string `json:"publish_error,omitempty"`
+}
+
+// DraftParams are the editable parts of a draft
+type DraftParams struct {
+	Body       string             `json:"body"`
+	InReplyTo  int                `json:"in_reply_to,omitempty"`
+	QuoteOf    int                `json:"quote_of,omitempty"`
+	Media      []AttachmentParams `json:"media,omitempty"`
+	Poll       *DraftPoll         `json:"poll,omitempty"`
+	Visibility string             `json:"visibility,omitempty"`
+	PublishAt  *time.Time         `json:"publish_at,omitempty"`
+}
+
+// DraftPoll is a poll waiting to be published, it closes ExpiresIn seconds
+// after publishing
+type DraftPoll struct {
+	Options   []string `json:"options"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// chirpParams are the params a draft publishes with at time at
+func (p DraftParams) chirpParams(at time.Time) ChirpParams {
+	params := ChirpParams{
+		Body:       p.Body,
+		InReplyTo:  p.InReplyTo,
+		QuoteOf:    p.QuoteOf,
+		Media:      p.Media,
+		Visibility: p.Visibility,
+	}
+	if p.Poll != nil {
+		params.Poll = &Poll{
+			Options:   p.Poll.Options,
+			ExpiresAt: at.Add(time.Duration(p.Poll.ExpiresIn) * time.Second),
+		}
+	}
+
+	return params
+}
+
+// checkDraft validates a draft as the chirp it would publish, must hold the
+// lock
+func (db *DB) checkDraft(authorID int, params DraftParams) error {
+	if params.PublishAt != nil {
+		ahead := time.Until(*params.PublishAt)
+		if ahead <= 0 || ahead > MaxScheduleAhead {
+			return ErrBadPublishAt
+		}
+	}
+
+	_, err := db.checkChirp(authorID, params.chirpParams(time.Now()))
+	return err
+}
+
+// CreateDraft saves a draft, scheduled when params.PublishAt is set
+func (db *DB) CreateDraft(authorID int, params DraftParams) (Draft, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	err := db.checkDraft(authorID, params)
+	if err != nil {
+		return Draft{}, err
+	}
+
+	now := time.Now().UTC()
+	draft := Draft{
+		Id:          db.database.NextDID,
+		AuthorId:    authorID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		DraftParams: params,
+	}
+	if draft.PublishAt != nil {
+		at := draft.PublishAt.UTC()
+		draft.PublishAt = &at
+	}
+	db.database.NextDID++
+	db.database.Drafts[draft.Id] = draft
+	db.index.draftsByAuthor[authorID] = insertSorted(db.index.draftsByAuthor[authorID], draft.Id)
+
+	return draft, db.store.SaveDraft(draft)
+}
+
+// GetDrafts lists an author's drafts and scheduled chirps, oldest first
+func (db *DB) GetDrafts(authorID int) ([]Draft, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	ids := db.index.draftsByAuthor[authorID]
+	drafts := make([]Draft, 0, len(ids))
+	for _, id := range ids {
+		drafts = append(drafts, db.database.Drafts[id])
+	}
+
+	return drafts, nil
+}
+
+// UpdateDraft replaces the content and schedule of one of the author's
+// drafts
+func (db *DB) UpdateDraft(id int, authorID int, params DraftParams) (Draft, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	draft, ok := db.database.Drafts[id]
+	if !ok || draft.AuthorId != authorID {
+		return Draft{}, ErrDraftNotFound
+	}
+
+	err := db.checkDraft(authorID, params)
+	if err != nil {
+		return Draft{}, err
+	}
+
+	draft.DraftParams = params
+	if draft.PublishAt != nil {
+		at := draft.PublishAt.UTC()
+		draft.PublishAt = &at
+	}
+	draft.PublishError = ""
+	draft.UpdatedAt = time.Now().UTC()
+	db.database.Drafts[id] = draft
+
+	return draft, db.store.SaveDraft(draft)
+}
+
+// DeleteDraft cancels one of the author's drafts or scheduled chirps
+func (db *DB) DeleteDraft(id int, authorID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	draft, ok := db.database.Drafts[id]
+	if !ok || draft.AuthorId != authorID {
+		return ErrDraftNotFound
+	}
+
+	db.removeDraft(draft)
+	return db.store.RemoveDraft(id)
+}
+
+// removeDraft forgets a draft, must hold the write lock
+func (db *DB) removeDraft(draft Draft) {
+	delete(db.database.Drafts, draft.Id)
+	removeKeyed(db.index.draftsByAuthor, draft.AuthorId, draft.Id)
+}
+
+// PublishDue publishes the scheduled chirps whose time has come, in the
+// order they were scheduled for. Chirps that fail validation, such as a
+// reply to a chirp deleted since, go back to being drafts with the reason.
+// Chirps over the author's rate limit stay scheduled until there is room.
+func (db *DB) PublishDue() ([]Chirp, error) {
+	now := time.Now()
+	due := db.dueDrafts(now)
+	published := []Chirp{}
+	if len(due) == 0 {
+		return published, nil
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	for _, id := range due {
+		// The draft may have been edited or deleted since it was found due
+		draft, ok := db.database.Drafts[id]
+		if !ok || draft.PublishAt == nil || draft.PublishAt.After(now) {
+			continue
+		}
+
+		chirp, err := db.createChirp(draft.AuthorId, draft.chirpParams(now))
+		if errors.Is(err, ErrRateLimited) {
+			continue
+		}
+		if err != nil {
+			draft.PublishAt = nil
+			draft.PublishError = err.Error()
+			draft.UpdatedAt = now.UTC()
+			db.database.Drafts[draft.Id] = draft
+			err = db.store.SaveDraft(draft)
+			if err != nil {
+				return published, err
+			}
+			continue
+		}
+		published = append(published, chirp)
+
+		db.removeDraft(draft)
+		err = db.store.RemoveDraft(draft.Id)
+		if err != nil {
+			return published, err
+		}
+	}
+
+	return published, nil
+}
+
+// dueDrafts finds the ids of the drafts scheduled at or before now, in the
+// order they were scheduled for. It only takes the read lock, so the
+// scheduler's checks don't hold up requests while nothing is due.
+func (db *DB) dueDrafts(now time.Time) []int {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	due := []Draft{}
+	for _, draft := range db.database.Drafts {
+		if draft.PublishAt != nil && !draft.PublishAt.After(now) {
+			due = append(due, draft)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].PublishAt.Equal(*due[j].PublishAt) {
+			return due[i].PublishAt.Before(*due[j].PublishAt)
+		}
+		return due[i].Id < due[j].Id
+	})
+
+	ids := make([]int, 0, len(due))
+	for _, draft := range due {
+		ids = append(ids, draft.Id)
+	}
+	return ids
+}
+
+// draftMedia is the set of uploads drafts hold on to, must hold the lock
+func (db *DB) draftMedia() map[int]bool {
+	used := map[int]bool{}
+	for _, draft := range db.database.Drafts {
+		for _, m := range draft.Media {
+			used[m.Id] = true
+		}
+	}
+	return used
+}
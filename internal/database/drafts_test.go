@@ -0,0 +1,122 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// makeDue moves a scheduled draft's publish time into the past
+func makeDue(db *DB, id int) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	draft := db.database.Drafts[id]
+	past := time.Now().Add(-time.Second)
+	draft.PublishAt = &past
+	db.database.Drafts[id] = draft
+	db.store.SaveDraft(draft)
+}
+
+func TestDrafts(t *testing.T) {
+	db := InitCleanDB()
+
+	past := time.Now().Add(-time.Minute)
+	_, err := db.CreateDraft(1, DraftParams{Body: "late", PublishAt: &past})
+	if !errors.Is(err, ErrBadPublishAt) {
+		t.Errorf("expected ErrBadPublishAt, got %v", err)
+	}
+
+	draft, err := db.CreateDraft(1, DraftParams{Body: "first go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	draft, err = db.UpdateDraft(draft.Id, 1, DraftParams{Body: "second go"})
+	if err != nil || draft.Body != "second go" {
+		t.Fatalf("expected edited draft, got %+v %v", draft, err)
+	}
+	_, err = db.UpdateDraft(draft.Id, 2, DraftParams{Body: "not mine"})
+	if !errors.Is(err, ErrDraftNotFound) {
+		t.Errorf("expected ErrDraftNotFound, got %v", err)
+	}
+
+	// Drafts stay out of every feed
+	chirps, _ := db.GetChirps()
+	if len(chirps) != 0 {
+		t.Errorf("expected no chirps, got %v", chirps)
+	}
+
+	err = db.DeleteDraft(draft.Id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	drafts, _ := db.GetDrafts(1)
+	if len(drafts) != 0 {
+		t.Errorf("expected draft to be cancelled, got %v", drafts)
+	}
+}
+
+func TestPublishDue(t *testing.T) {
+	db := InitCleanDB()
+
+	parent, _ := db.CreateChirp(2, "parent")
+	soon := time.Now().Add(time.Hour)
+	reply, _ := db.CreateDraft(1, DraftParams{Body: "reply", InReplyTo: parent.Id, PublishAt: &soon})
+	later, _ := db.CreateDraft(1, DraftParams{Body: "later", PublishAt: &soon})
+	plain, _ := db.CreateDraft(1, DraftParams{Body: "plain", PublishAt: &soon})
+
+	published, err := db.PublishDue()
+	if err != nil || len(published) != 0 {
+		t.Fatalf("expected nothing due, got %v %v", published, err)
+	}
+
+	// A reply whose parent went away goes back to the drafts
+	db.DeleteChirp(parent.Id)
+	makeDue(db, reply.Id)
+	makeDue(db, plain.Id)
+	published, err = db.PublishDue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 1 || published[0].Body != "plain" {
+		t.Errorf("expected plain to be published, got %v", published)
+	}
+
+	drafts, _ := db.GetDrafts(1)
+	if len(drafts) != 2 || drafts[0].Id != reply.Id || drafts[1].Id != later.Id {
+		t.Fatalf("expected reply and later left, got %+v", drafts)
+	}
+	if drafts[0].PublishAt != nil || drafts[0].PublishError == "" {
+		t.Errorf("expected failed draft to be unscheduled with a reason, got %+v", drafts[0])
+	}
+}
+
+func TestScheduledSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	err := os.WriteFile(path, []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	soon := time.Now().Add(time.Hour)
+	draft, _ := db.CreateDraft(1, DraftParams{Body: "tomorrow", PublishAt: &soon})
+	makeDue(db, draft.Id)
+	db.Close()
+
+	db, err = InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	published, err := db.PublishDue()
+	if err != nil || len(published) != 1 || published[0].Body != "tomorrow" {
+		t.Errorf("expected pending chirp published after restart, got %v %v", published, err)
+	}
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Longest tag or handle taken from a chirp body, in runes
+const maxEntityLength = 100
+
+// ExtractTags returns the distinct #tags in body, lowercased and without the
+// #, in order of appearance. Tags made only of digits are ignored.
+func ExtractTags(body string) []string {
+	return extractEntities(body, '#', func(word string) bool {
+		return strings.IndexFunc(word, func(r rune) bool { return !unicode.IsDigit(r) }) >= 0
+	})
+}
+
+// ExtractMentions returns the distinct @handles in body, lowercased and
+// without the @, in order of appearance. Email addresses are not mentions.
+func ExtractMentions(body string) []string {
+	return extractEntities(body, '@', func(word string) bool { return true })
+}
+
+// extractEntities finds words following sigil at the start of body or after a
+// non-word character
+func extractEntities(body string, sigil rune, keep func(string) bool) []string {
+	found := []string{}
+	seen := map[string]bool{}
+
+	prev := ' '
+	for i := 0; i < len(body); {
+		r, size := utf8.DecodeRuneInString(body[i:])
+		if r != sigil || isWordRune(prev) {
+			prev = r
+			i += size
+			continue
+		}
+
+		// Read the word after the sigil
+		prev = r
+		i += size
+		start := i
+		runes := 0
+		for i < len(body) {
+			wr, wsize := utf8.DecodeRuneInString(body[i:])
+			if !isWordRune(wr) {
+				break
+			}
+			prev = wr
+			i += wsize
+			runes++
+		}
+
+		word := strings.ToLower(body[start:i])
+		if runes > 0 && runes <= maxEntityLength && keep(word) && !seen[word] {
+			seen[word] = true
+			found = append(found, word)
+		}
+	}
+
+	return found
+}
+
+// isWordRune reports whether r can be part of a tag or handle
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
@@ -0,0 +1,244 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+)
+
+// Plans of the default config. Every config has a free plan, which users
+// start on.
+const (
+	PlanFree = "free"
+	PlanRed  = "red"
+)
+
+// Window the chirp rate limit counts over
+const RateWindow = time.Hour
+
+var ErrUnknownPlan error = errors.New("unknown plan")
+var ErrRateLimited error = errors.New("chirp rate limit reached, try again later")
+var ErrBadPlanConfig error = errors.New("plan config must define a free plan")
+
+// Plan is what a subscription tier entitles its users to
+type Plan struct {
+	Name string `json:"name"`
+
+	// Longest chirp body, in bytes
+	MaxChirpLength int `json:"max_chirp_length"`
+
+	// Most images on one chirp
+	MaxChirpMedia int `json:"max_chirp_media"`
+
+	// Most chirps pinned at once
+	MaxPins int `json:"max_pins"`
+
+	// How long after posting chirps can be edited, 0 for no limit
+	EditWindow time.Duration `json:"-"`
+
+	// Most chirps posted within RateWindow, 0 for no limit
+	ChirpsPerHour int `json:"chirps_per_hour"`
+}
+
+// MarshalJSON writes the edit window in seconds, like other durations in the
+// API
+func (p Plan) MarshalJSON() ([]byte, error) {
+	type plan Plan
+	return json.Marshal(struct {
+		plan
+		EditWindow int `json:"edit_window"`
+	}{plan(p), int(p.EditWindow / time.Second)})
+}
+
+func (p *Plan) UnmarshalJSON(dat []byte) error {
+	type plan Plan
+	v := struct {
+		*plan
+		EditWindow int `json:"edit_window"`
+	}{plan: (*plan)(p)}
+	err := json.Unmarshal(dat, &v)
+	if err != nil {
+		return err
+	}
+	p.EditWindow = time.Duration(v.EditWindow) * time.Second
+	return nil
+}
+
+// PlanConfig holds the plans on offer and which Polka webhook events move a
+// user onto which plan
+type PlanConfig struct {
+	Plans       map[string]Plan   `json:"plans"`
+	PolkaEvents map[string]string `json:"polka_events"`
+}
+
+// DefaultPlanConfig is used until another config is set
+func DefaultPlanConfig() PlanConfig {
+	return PlanConfig{
+		Plans: map[string]Plan{
+			PlanFree: {
+				Name:           PlanFree,
+				MaxChirpLength: MaxChirpLength,
+				MaxChirpMedia:  MaxChirpMedia,
+				MaxPins:        MaxPins,
+				ChirpsPerHour:  100,
+			},
+			PlanRed: {
+				Name:           PlanRed,
+				MaxChirpLength: 2 * MaxChirpLength,
+				MaxChirpMedia:  2 * MaxChirpMedia,
+				MaxPins:        MaxRedPins,
+				ChirpsPerHour:  1000,
+			},
+		},
+		PolkaEvents: map[string]string{
+			"user.upgraded":   PlanRed,
+			"user.downgraded": PlanFree,
+		},
+	}
+}
+
+// LoadPlanConfig reads a PlanConfig from a JSON file
+func LoadPlanConfig(path string) (PlanConfig, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return PlanConfig{}, err
+	}
+
+	cfg := PlanConfig{}
+	err = json.Unmarshal(dat, &cfg)
+	if err != nil {
+		return PlanConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// SetPlanConfig replaces the plans on offer. Users on a plan the config
+// drops are moved to the free plan.
+func (db *DB) SetPlanConfig(cfg PlanConfig) error {
+	if _, ok := cfg.Plans[PlanFree]; !ok {
+		return ErrBadPlanConfig
+	}
+	for event, name := range cfg.PolkaEvents {
+		if _, ok := cfg.Plans[name]; !ok {
+			return errors.New("polka event " + event + " maps to unknown plan " + name)
+		}
+	}
+
+	plans := make(map[string]Plan, len(cfg.Plans))
+	for name, plan := range cfg.Plans {
+		plan.Name = name
+		plans[name] = plan
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.plans = plans
+	db.polkaEvents = cfg.PolkaEvents
+
+	for _, user := range db.database.Users {
+		if _, ok := plans[user.Plan]; ok {
+			continue
+		}
+		user.Plan = PlanFree
+		user.IsChirpyRed = false
+		db.database.Users[user.Id] = user
+		err := db.store.SaveUser(user)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetEditWindow limits how long after posting a chirp can be edited on the
+// free plan, 0 for no limit
+func (db *DB) SetEditWindow(window time.Duration) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	free := db.plans[PlanFree]
+	free.EditWindow = window
+	db.plans[PlanFree] = free
+}
+
+// Entitlements returns the plan of a user
+func (db *DB) Entitlements(userID int) (Plan, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.database.Users[userID]; !ok {
+		return Plan{}, ErrUserNotFound
+	}
+	return db.entitlements(userID), nil
+}
+
+// entitlements must hold the lock
+func (db *DB) entitlements(userID int) Plan {
+	plan, ok := db.plans[db.database.Users[userID].Plan]
+	if !ok {
+		return db.plans[PlanFree]
+	}
+	return plan
+}
+
+// PolkaPlan returns the plan a Polka webhook event moves a user onto, false
+// for events that don't change plans
+func (db *DB) PolkaPlan(event string) (string, bool) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	plan, ok := db.polkaEvents[event]
+	return plan, ok
+}
+
+// SetUserPlan moves a user onto a plan
+func (db *DB) SetUserPlan(userID int, plan string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.database.Users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := db.plans[plan]; !ok {
+		return ErrUnknownPlan
+	}
+
+	user.Plan = plan
+	user.IsChirpyRed = plan != PlanFree
+	db.database.Users[userID] = user
+
+	return db.store.SaveUser(user)
+}
+
+// checkRate rejects a new chirp by authorID past their plan's rate limit,
+// must hold the lock. Chirps deleted since count too, so deleting doesn't
+// make room for more.
+func (db *DB) checkRate(authorID int) error {
+	limit := db.entitlements(authorID).ChirpsPerHour
+	if limit <= 0 {
+		return nil
+	}
+
+	since := time.Now().Add(-RateWindow)
+	posted := db.countSince(db.index.chirpsByAuthor[authorID], since) +
+		db.countSince(db.index.trashByAuthor[authorID], since)
+	if posted >= limit {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// countSince counts the chirps among ascending ids created after since,
+// must hold the lock
+func (db *DB) countSince(ids []int, since time.Time) int {
+	// Ids ascend with creation time, so the window is a suffix of the ids
+	start := sort.Search(len(ids), func(i int) bool {
+		return db.database.Chirps[ids[i]].CreatedAt.After(since)
+	})
+	return len(ids) - start
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlanLimits(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	long := strings.Repeat("a", MaxChirpLength+1)
+
+	if _, err := db.CreateChirp(alice.Id, long); !errors.Is(err, ErrChirpTooLong) {
+		t.Errorf("expected ErrChirpTooLong on the free plan, got %v", err)
+	}
+
+	plan, _ := db.PolkaPlan("user.upgraded")
+	err := db.SetUserPlan(alice.Id, plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateChirp(alice.Id, long); err != nil {
+		t.Errorf("expected Chirpy Red to allow longer chirps, got %v", err)
+	}
+	if user, _ := db.GetUserById(alice.Id); !user.IsChirpyRed || user.Plan != PlanRed {
+		t.Errorf("expected user on the red plan, got %+v", user)
+	}
+
+	if err := db.SetUserPlan(alice.Id, "gold"); !errors.Is(err, ErrUnknownPlan) {
+		t.Errorf("expected ErrUnknownPlan, got %v", err)
+	}
+}
+
+func TestPlanConfig(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+
+	if err := db.SetPlanConfig(PlanConfig{}); !errors.Is(err, ErrBadPlanConfig) {
+		t.Errorf("expected ErrBadPlanConfig, got %v", err)
+	}
+
+	cfg := PlanConfig{}
+	err := json.Unmarshal([]byte(`{
+		"plans": {"free": {"max_chirp_length": 140, "max_chirp_media": 1, "max_pins": 1, "edit_window": 60, "chirps_per_hour": 2}},
+		"polka_events": {"user.downgraded": "free"}
+	}`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetUserPlan(alice.Id, PlanRed)
+	err = db.SetPlanConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dropping the red plan moves its users to the free plan
+	if user, _ := db.GetUserById(alice.Id); user.IsChirpyRed || user.Plan != PlanFree {
+		t.Errorf("expected user moved to the free plan, got %+v", user)
+	}
+
+	plan, _ := db.Entitlements(alice.Id)
+	if plan.Name != PlanFree || plan.EditWindow != time.Minute {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if _, ok := db.PolkaPlan("user.upgraded"); ok {
+		t.Error("expected unmapped event to be ignored")
+	}
+
+	one, _ := db.CreateChirp(alice.Id, "one")
+	db.CreateChirp(alice.Id, "two")
+	if _, err := db.CreateChirp(alice.Id, "three"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+
+	// Deleting a chirp doesn't make room
+	db.DeleteChirp(one.Id)
+	if _, err := db.CreateChirp(alice.Id, "three"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected ErrRateLimited after deleting, got %v", err)
+	}
+
+	// Scheduled chirps wait for room instead of failing
+	soon := time.Now().Add(time.Minute)
+	draft, _ := db.CreateDraft(alice.Id, DraftParams{Body: "later", PublishAt: &soon})
+	makeDue(db, draft.Id)
+	published, err := db.PublishDue()
+	if err != nil || len(published) != 0 {
+		t.Errorf("expected nothing published, got %v %v", published, err)
+	}
+	if drafts, _ := db.GetDrafts(alice.Id); len(drafts) != 1 || drafts[0].PublishAt == nil || drafts[0].PublishError != "" {
+		t.Errorf("expected the draft to stay scheduled, got %+v", drafts)
+	}
+}
@@ -0,0 +1,132 @@
+package database
+
+import "slices"
+
+// chirpFilter holds what one viewer is kept from seeing. Every chirp read
+// goes through the filter of its viewer.
+type chirpFilter struct {
+	viewerID int
+
+	// Authors blocking or blocked by the viewer, hidden everywhere
+	blocked map[int]bool
+
+	// Authors the viewer muted, hidden from lists only
+	muted map[int]bool
+
+	// follows reports whether the viewer follows an author
+	follows func(authorID int) bool
+}
+
+// filterFor builds the filter of viewerID, an anonymous viewer of 0 only
+// sees public chirps. Must hold the lock.
+func (db *DB) filterFor(viewerID int) chirpFilter {
+	f := chirpFilter{
+		viewerID: viewerID,
+		follows: func(authorID int) bool {
+			return db.hasRelation(RelationFollow, viewerID, authorID)
+		},
+	}
+	if viewerID == 0 {
+		return f
+	}
+
+	blocks := db.index.relationsFrom.get(RelationBlock, viewerID)
+	blockers := db.index.relationsTo.get(RelationBlock, viewerID)
+	if len(blocks)+len(blockers) > 0 {
+		f.blocked = make(map[int]bool, len(blocks)+len(blockers))
+		for _, id := range blocks {
+			f.blocked[id] = true
+		}
+		for _, id := range blockers {
+			f.blocked[id] = true
+		}
+	}
+
+	mutes := db.index.relationsFrom.get(RelationMute, viewerID)
+	if len(mutes) > 0 {
+		f.muted = make(map[int]bool, len(mutes))
+		for _, id := range mutes {
+			f.muted[id] = true
+		}
+	}
+
+	return f
+}
+
+// inAudience reports whether the visibility of a chirp lets the viewer read
+// it
+func (f chirpFilter) inAudience(chirp Chirp) bool {
+	if f.viewerID != 0 && chirp.AuthorId == f.viewerID {
+		return true
+	}
+
+	switch chirp.Visibility {
+	case VisibilityFollowers:
+		return f.viewerID != 0 && f.follows(chirp.AuthorId)
+	case VisibilityDirect:
+		return f.viewerID != 0 && slices.Contains(chirp.Recipients, f.viewerID)
+	default:
+		return true
+	}
+}
+
+// listed reports whether a chirp appears in the viewer's feeds, timelines
+// and searches
+func (f chirpFilter) listed(chirp Chirp) bool {
+	return !f.muted[chirp.AuthorId] && f.shown(chirp)
+}
+
+// shown reports whether the viewer can open a chirp directly, mutes don't
+// stop that
+func (f chirpFilter) shown(chirp Chirp) bool {
+	return !f.blocked[chirp.AuthorId] && f.inAudience(chirp)
+}
+
+// listedIDs drops the ids of chirps the filter keeps out of lists, must hold
+// the lock
+func (db *DB) listedIDs(ids []int, f chirpFilter) []int {
+	listed := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if chirp, ok := db.database.Chirps[id]; ok && f.listed(chirp) {
+			listed = append(listed, id)
+		}
+	}
+	return listed
+}
+
+// firstListed takes up to n listed ids from the start of ascending ids, or
+// from the end when desc, keeping them ascending. Must hold the lock.
+func (db *DB) firstListed(ids []int, f chirpFilter, n int, desc bool) []int {
+	taken := []int{}
+	for i := 0; i < len(ids) && len(taken) < n; i++ {
+		id := ids[i]
+		if desc {
+			id = ids[len(ids)-1-i]
+		}
+		if chirp, ok := db.database.Chirps[id]; ok && f.listed(chirp) {
+			taken = append(taken, id)
+		}
+	}
+
+	if desc {
+		slices.Reverse(taken)
+	}
+	return taken
+}
+
+// GetVisibleChirp gets a chirp for viewerID. Chirps hidden from them by a
+// block or by their visibility are not found.
+func (db *DB) GetVisibleChirp(id int, viewerID int) (Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	chirp, ok := db.database.Chirps[id]
+	if !ok || !db.filterFor(viewerID).shown(chirp) {
+		return Chirp{}, ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return chirp, ErrChirpDeleted
+	}
+
+	return chirp, nil
+}
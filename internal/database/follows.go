@@ -0,0 +1,240 @@
+package database
+
+import (
+	"errors"
+	"slices"
+)
+
+// Chirps by authors with up to this many followers are fanned out on write,
+// copied into each follower's timeline as they are posted. Past it a chirp
+// would cost one insert per follower, so those authors are fanned out on read
+// instead and merged into the timeline when it is requested.
+var FanOutFollowerLimit = 1000
+
+var ErrFollowSelf error = errors.New("users can't follow themselves")
+
+type UserPage struct {
+	Users []PublicUser
+
+	// Cursor for the following page, 0 when this is the last
+	Next int
+}
+
+// Follow makes followerID follow userID, following twice is a no-op
+func (db *DB) Follow(followerID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if followerID == userID {
+		return ErrFollowSelf
+	}
+	if _, ok := db.database.Users[userID]; !ok {
+		return ErrUserNotFound
+	}
+	if db.blocked(followerID, userID) {
+		return ErrBlocked
+	}
+
+	_, err := db.addRelation(RelationFollow, followerID, userID)
+	return err
+}
+
+// Unfollow stops followerID following userID, a no-op if they weren't
+func (db *DB) Unfollow(followerID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.database.Users[userID]; !ok {
+		return ErrUserNotFound
+	}
+
+	_, err := db.removeRelation(RelationFollow, followerID, userID)
+	return err
+}
+
+// GetFollowers pages through the users following userID by user id
+func (db *DB) GetFollowers(userID int, q ChirpQuery) (UserPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.database.Users[userID]; !ok {
+		return UserPage{}, ErrUserNotFound
+	}
+
+	return db.pageUsers(db.index.relationsTo.get(RelationFollow, userID), q), nil
+}
+
+// GetFollowing pages through the users userID follows by user id
+func (db *DB) GetFollowing(userID int, q ChirpQuery) (UserPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.database.Users[userID]; !ok {
+		return UserPage{}, ErrUserNotFound
+	}
+
+	return db.pageUsers(db.index.relationsFrom.get(RelationFollow, userID), q), nil
+}
+
+// GetTimeline returns a page of chirps by the authors userID follows,
+// merging the fanned out timeline with the chirps of popular authors. Muted
+// authors are left out.
+func (db *DB) GetTimeline(userID int, q ChirpQuery) (ChirpPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	q.ViewerID = userID
+	lists := [][]int{db.index.timelines[userID]}
+	for _, authorID := range db.index.relationsFrom.get(RelationFollow, userID) {
+		if !db.index.fannedOut(authorID) {
+			lists = append(lists, db.index.chirpsByAuthor[authorID])
+		}
+	}
+
+	return db.pageChirps(db.mergeWindows(lists, q), q), nil
+}
+
+// pageUsers walks ascending user ids by After, Desc and Limit, must hold the
+// lock
+func (db *DB) pageUsers(ids []int, q ChirpQuery) UserPage {
+	limit := pageLimit(q)
+	page := UserPage{Users: []PublicUser{}}
+
+	low, high := 0, len(ids)
+	if q.After > 0 {
+		i, found := slices.BinarySearch(ids, q.After)
+		if q.Desc {
+			high = i
+		} else if found {
+			low = i + 1
+		} else {
+			low = i
+		}
+	}
+
+	for n := 0; n < high-low; n++ {
+		i := low + n
+		if q.Desc {
+			i = high - 1 - n
+		}
+
+		user, ok := db.database.Users[ids[i]]
+		if !ok {
+			continue
+		}
+		page.Users = append(page.Users, user.Public())
+
+		// Like pageChirps, only set a cursor when another user follows
+		if len(page.Users) > limit {
+			page.Users = page.Users[:limit]
+			page.Next = page.Users[limit-1].Id
+			break
+		}
+	}
+
+	return page
+}
+
+// fannedOut reports whether the chirps of authorID are copied into their
+// followers' timelines
+func (ix *indexes) fannedOut(authorID int) bool {
+	return len(ix.relationsTo.get(RelationFollow, authorID)) <= FanOutFollowerLimit
+}
+
+// buildTimelines fans out the chirps of every author under the limit
+func (ix *indexes) buildTimelines() {
+	for authorID, followers := range ix.relationsTo[RelationFollow] {
+		if len(followers) > FanOutFollowerLimit {
+			continue
+		}
+		for _, followerID := range followers {
+			ix.timelines[followerID] = append(ix.timelines[followerID], ix.chirpsByAuthor[authorID]...)
+		}
+	}
+
+	for _, ids := range ix.timelines {
+		slices.Sort(ids)
+	}
+}
+
+// pushChirp copies a new chirp into the author's followers' timelines
+func (ix *indexes) pushChirp(chirp Chirp) {
+	if !ix.fannedOut(chirp.AuthorId) {
+		return
+	}
+	for _, followerID := range ix.relationsTo.get(RelationFollow, chirp.AuthorId) {
+		ix.timelines[followerID] = insertSorted(ix.timelines[followerID], chirp.Id)
+	}
+}
+
+// unpushChirp takes a chirp back out of the followers' timelines
+func (ix *indexes) unpushChirp(chirp Chirp) {
+	if !ix.fannedOut(chirp.AuthorId) {
+		return
+	}
+	for _, followerID := range ix.relationsTo.get(RelationFollow, chirp.AuthorId) {
+		removeKeyed(ix.timelines, followerID, chirp.Id)
+	}
+}
+
+// follow updates the timelines after followerID started following authorID
+func (ix *indexes) follow(followerID int, authorID int) {
+	followers := ix.relationsTo.get(RelationFollow, authorID)
+	chirps := ix.chirpsByAuthor[authorID]
+
+	switch {
+	case len(followers) <= FanOutFollowerLimit:
+		ix.timelines[followerID] = mergeSorted(ix.timelines[followerID], chirps)
+	case len(followers) == FanOutFollowerLimit+1:
+		// The author just became popular, read their chirps from now on
+		for _, id := range followers {
+			if id != followerID {
+				ix.setTimeline(id, subtractSorted(ix.timelines[id], chirps))
+			}
+		}
+	}
+}
+
+// unfollow updates the timelines after followerID stopped following authorID
+func (ix *indexes) unfollow(followerID int, authorID int) {
+	followers := ix.relationsTo.get(RelationFollow, authorID)
+	chirps := ix.chirpsByAuthor[authorID]
+
+	switch {
+	case len(followers) < FanOutFollowerLimit:
+		ix.setTimeline(followerID, subtractSorted(ix.timelines[followerID], chirps))
+	case len(followers) == FanOutFollowerLimit:
+		// The author dropped back under the limit, fan out their chirps again
+		for _, id := range followers {
+			ix.timelines[id] = mergeSorted(ix.timelines[id], chirps)
+		}
+	}
+}
+
+func (ix *indexes) setTimeline(userID int, ids []int) {
+	if len(ids) == 0 {
+		delete(ix.timelines, userID)
+		return
+	}
+	ix.timelines[userID] = ids
+}
+
+// mergeSorted returns the union of two ascending slices
+func mergeSorted(a []int, b []int) []int {
+	merged := make([]int, 0, len(a)+len(b))
+	merged = append(merged, a...)
+	merged = append(merged, b...)
+	slices.Sort(merged)
+	return slices.Compact(merged)
+}
+
+// subtractSorted returns the ids of ascending a that are not in ascending b
+func subtractSorted(a []int, b []int) []int {
+	kept := []int{}
+	for _, id := range a {
+		if _, found := slices.BinarySearch(b, id); !found {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestFollowTimeline(t *testing.T) {
+	db := InitCleanDB()
+	for i := 0; i < 3; i++ {
+		db.CreateUser(fmt.Sprintf("user%d@x.y", i), "password")
+	}
+
+	db.CreateChirp(2, "before follow")
+	err := db.Follow(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Follow(1, 2)
+	db.Follow(1, 3)
+	db.CreateChirp(3, "after follow")
+	db.CreateChirp(1, "own chirp")
+
+	if err := db.Follow(1, 1); !errors.Is(err, ErrFollowSelf) {
+		t.Errorf("expected ErrFollowSelf, got %v", err)
+	}
+	if err := db.Follow(1, 99); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound, got %v", err)
+	}
+
+	page, _ := db.GetTimeline(1, ChirpQuery{})
+	if !slices.Equal(pageIDs(page), []int{1, 2}) {
+		t.Errorf("expected [1 2], got %v", pageIDs(page))
+	}
+
+	following, _ := db.GetFollowing(1, ChirpQuery{})
+	if len(following.Users) != 2 {
+		t.Errorf("expected 2 followed users, got %v", following.Users)
+	}
+
+	db.Unfollow(1, 2)
+	page, _ = db.GetTimeline(1, ChirpQuery{})
+	if !slices.Equal(pageIDs(page), []int{2}) {
+		t.Errorf("expected [2] after unfollow, got %v", pageIDs(page))
+	}
+
+	db.DeleteChirp(2)
+	page, _ = db.GetTimeline(1, ChirpQuery{})
+	if len(page.Chirps) != 0 {
+		t.Errorf("expected deleted chirp out of timeline, got %v", pageIDs(page))
+	}
+}
+
+func TestTimelineFanOutOnRead(t *testing.T) {
+	limit := FanOutFollowerLimit
+	FanOutFollowerLimit = 2
+	defer func() { FanOutFollowerLimit = limit }()
+
+	db := InitCleanDB()
+	for i := 0; i < 4; i++ {
+		db.CreateUser(fmt.Sprintf("user%d@x.y", i), "password")
+	}
+	db.CreateChirp(1, "popular")
+
+	// Followers 2 and 3 get the chirp pushed, 4 tips author 1 over the limit
+	for id := 2; id <= 4; id++ {
+		db.Follow(id, 1)
+	}
+	if !slices.Equal(db.index.timelines[2], nil) {
+		t.Errorf("expected pushed chirps dropped, got %v", db.index.timelines[2])
+	}
+	db.CreateChirp(1, "read on demand")
+
+	for id := 2; id <= 4; id++ {
+		page, _ := db.GetTimeline(id, ChirpQuery{Desc: true, Limit: 1})
+		if !slices.Equal(pageIDs(page), []int{2}) || page.Next != 2 {
+			t.Errorf("user %d: expected [2] next 2, got %v next %d", id, pageIDs(page), page.Next)
+		}
+	}
+
+	db.Unfollow(4, 1)
+	if !slices.Equal(db.index.timelines[2], []int{1, 2}) {
+		t.Errorf("expected chirps pushed again, got %v", db.index.timelines[2])
+	}
+
+	followers, _ := db.GetFollowers(1, ChirpQuery{Limit: 1})
+	if len(followers.Users) != 1 || followers.Next != 2 {
+		t.Errorf("expected one follower next 2, got %v next %d", followers.Users, followers.Next)
+	}
+}
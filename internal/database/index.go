@@ -0,0 +1,278 @@
+package database
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// indexes are lookups derived from the Database. They are never persisted,
+// rebuilt on load and updated under the same lock as the mutation they track.
+type indexes struct {
+	// Lowercased email -> user id
+	usersByEmail map[string]int
+
+	// Lowercased handle -> user id
+	usersByHandle map[string]int
+
+	// Every chirp id, ascending
+	chirpIDs []int
+
+	// Author id -> chirp ids, ascending
+	chirpsByAuthor map[int][]int
+
+	// Author id -> ids of deleted chirps, ascending
+	trashByAuthor map[int][]int
+
+	// Chirp id -> ids of its direct replies, ascending. Deleted chirps stay
+	// until purged so their replies keep their place in the thread.
+	repliesByParent map[int][]int
+
+	// Relation kind -> source id -> target ids, and the reverse
+	relationsFrom relationIndex
+	relationsTo   relationIndex
+
+	// User id -> ids of chirps fanned out to them on write, ascending
+	timelines map[int][]int
+
+	// Lowercased tag -> ids of chirps using it, ascending
+	chirpsByTag map[string][]int
+
+	// User id -> ids of chirps mentioning them, ascending
+	chirpsByMention map[int][]int
+
+	// Words of live chirp bodies
+	search *searchIndex
+
+	// Media id -> number of chirps, live or deleted, showing it
+	mediaRefs map[int]int
+
+	// Content hash -> ids of uploads sharing its files, ascending
+	mediaByHash map[string][]int
+
+	// Chirp id -> votes for each poll option
+	pollVotes map[int][]int
+
+	// Author id -> ids of their drafts, ascending
+	draftsByAuthor map[int][]int
+
+	// Owner id -> ids of their lists, ascending
+	listsByOwner map[int][]int
+}
+
+func buildIndexes(d *Database) *indexes {
+	ix := &indexes{
+		usersByEmail:   make(map[string]int, len(d.Users)),
+		usersByHandle:  make(map[string]int, len(d.Users)),
+		chirpsByAuthor: make(map[int][]int),
+		trashByAuthor:  make(map[int][]int),
+
+		repliesByParent: make(map[int][]int),
+		relationsFrom:   make(relationIndex),
+		relationsTo:     make(relationIndex),
+		timelines:       make(map[int][]int),
+		chirpsByTag:     make(map[string][]int),
+		chirpsByMention: make(map[int][]int),
+		search:          newSearchIndex(),
+		mediaRefs:       make(map[int]int),
+		mediaByHash:     make(map[string][]int),
+		pollVotes:       make(map[int][]int),
+		draftsByAuthor:  make(map[int][]int),
+		listsByOwner:    make(map[int][]int),
+	}
+
+	for _, user := range d.Users {
+		ix.usersByEmail[emailKey(user.Email)] = user.Id
+		if user.Handle != "" {
+			ix.usersByHandle[handleKey(user.Handle)] = user.Id
+		}
+	}
+
+	for _, m := range d.Media {
+		ix.mediaByHash[m.Hash] = insertSorted(ix.mediaByHash[m.Hash], m.Id)
+	}
+
+	for _, chirp := range d.Chirps {
+		ix.addAttachments(chirp)
+		if chirp.InReplyTo != 0 {
+			ix.repliesByParent[chirp.InReplyTo] = append(ix.repliesByParent[chirp.InReplyTo], chirp.Id)
+		}
+		if chirp.DeletedAt != nil {
+			ix.trashByAuthor[chirp.AuthorId] = append(ix.trashByAuthor[chirp.AuthorId], chirp.Id)
+			continue
+		}
+		ix.chirpIDs = append(ix.chirpIDs, chirp.Id)
+		ix.chirpsByAuthor[chirp.AuthorId] = append(ix.chirpsByAuthor[chirp.AuthorId], chirp.Id)
+	}
+	sort.Ints(ix.chirpIDs)
+	for _, id := range ix.chirpIDs {
+		ix.addEntities(d.Chirps[id])
+		ix.search.add(d.Chirps[id])
+	}
+	for _, ids := range ix.chirpsByAuthor {
+		sort.Ints(ids)
+	}
+	for _, ids := range ix.trashByAuthor {
+		sort.Ints(ids)
+	}
+	for _, ids := range ix.repliesByParent {
+		sort.Ints(ids)
+	}
+
+	for _, rel := range d.Relations {
+		ix.relationsFrom.append(rel.Kind, rel.From, rel.To)
+		ix.relationsTo.append(rel.Kind, rel.To, rel.From)
+		ix.countVote(rel, 1)
+	}
+	ix.relationsFrom.sort()
+	ix.relationsTo.sort()
+
+	for _, draft := range d.Drafts {
+		ix.draftsByAuthor[draft.AuthorId] = append(ix.draftsByAuthor[draft.AuthorId], draft.Id)
+	}
+	for _, ids := range ix.draftsByAuthor {
+		sort.Ints(ids)
+	}
+
+	for _, l := range d.Lists {
+		ix.listsByOwner[l.OwnerId] = append(ix.listsByOwner[l.OwnerId], l.Id)
+	}
+	for _, ids := range ix.listsByOwner {
+		sort.Ints(ids)
+	}
+
+	ix.buildTimelines()
+
+	return ix
+}
+
+func emailKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (ix *indexes) setUser(old User, user User) {
+	if old.Email != "" && emailKey(old.Email) != emailKey(user.Email) {
+		delete(ix.usersByEmail, emailKey(old.Email))
+	}
+	ix.usersByEmail[emailKey(user.Email)] = user.Id
+
+	if old.Handle != "" && handleKey(old.Handle) != handleKey(user.Handle) {
+		delete(ix.usersByHandle, handleKey(old.Handle))
+	}
+	if user.Handle != "" {
+		ix.usersByHandle[handleKey(user.Handle)] = user.Id
+	}
+}
+
+func (ix *indexes) addChirp(chirp Chirp) {
+	ix.chirpIDs = insertSorted(ix.chirpIDs, chirp.Id)
+	ix.chirpsByAuthor[chirp.AuthorId] = insertSorted(ix.chirpsByAuthor[chirp.AuthorId], chirp.Id)
+	ix.pushChirp(chirp)
+	ix.addEntities(chirp)
+	ix.search.add(chirp)
+}
+
+func (ix *indexes) removeChirp(chirp Chirp) {
+	ix.chirpIDs = removeSorted(ix.chirpIDs, chirp.Id)
+	removeKeyed(ix.chirpsByAuthor, chirp.AuthorId, chirp.Id)
+	ix.unpushChirp(chirp)
+	ix.removeEntities(chirp)
+	ix.search.remove(chirp)
+}
+
+// editChirp reindexes what a new body changes
+func (ix *indexes) editChirp(old Chirp, chirp Chirp) {
+	ix.removeEntities(old)
+	ix.addEntities(chirp)
+	ix.search.remove(old)
+	ix.search.add(chirp)
+}
+
+func (ix *indexes) addRelation(rel Relation) {
+	ix.relationsFrom.add(rel.Kind, rel.From, rel.To)
+	ix.relationsTo.add(rel.Kind, rel.To, rel.From)
+	ix.countVote(rel, 1)
+	if rel.Kind == RelationFollow {
+		ix.follow(rel.From, rel.To)
+	}
+}
+
+func (ix *indexes) removeRelation(rel Relation) {
+	ix.relationsFrom.remove(rel.Kind, rel.From, rel.To)
+	ix.relationsTo.remove(rel.Kind, rel.To, rel.From)
+	ix.countVote(rel, -1)
+	if rel.Kind == RelationFollow {
+		ix.unfollow(rel.From, rel.To)
+	}
+}
+
+// trashChirp moves a deleted chirp out of the live indexes
+func (ix *indexes) trashChirp(chirp Chirp) {
+	ix.removeChirp(chirp)
+	ix.trashByAuthor[chirp.AuthorId] = insertSorted(ix.trashByAuthor[chirp.AuthorId], chirp.Id)
+}
+
+// untrashChirp puts a restored chirp back in the live indexes
+func (ix *indexes) untrashChirp(chirp Chirp) {
+	removeKeyed(ix.trashByAuthor, chirp.AuthorId, chirp.Id)
+	ix.addChirp(chirp)
+}
+
+// purgeChirp forgets a chirp that is gone for good, its replies stay
+// indexed under it as orphans
+func (ix *indexes) purgeChirp(chirp Chirp) {
+	ix.removeChirp(chirp)
+	removeKeyed(ix.trashByAuthor, chirp.AuthorId, chirp.Id)
+	if chirp.InReplyTo != 0 {
+		removeKeyed(ix.repliesByParent, chirp.InReplyTo, chirp.Id)
+	}
+	for _, a := range chirp.Media {
+		ix.mediaRefs[a.Id]--
+		if ix.mediaRefs[a.Id] <= 0 {
+			delete(ix.mediaRefs, a.Id)
+		}
+	}
+}
+
+func (ix *indexes) addReply(chirp Chirp) {
+	if chirp.InReplyTo != 0 {
+		ix.repliesByParent[chirp.InReplyTo] = insertSorted(ix.repliesByParent[chirp.InReplyTo], chirp.Id)
+	}
+}
+
+func (ix *indexes) addAttachments(chirp Chirp) {
+	for _, a := range chirp.Media {
+		ix.mediaRefs[a.Id]++
+	}
+}
+
+// removeKeyed removes id from the sorted ids under key, dropping empty keys
+func removeKeyed[K comparable](m map[K][]int, key K, id int) {
+	ids := removeSorted(m[key], id)
+	if len(ids) == 0 {
+		delete(m, key)
+		return
+	}
+	m[key] = ids
+}
+
+// insertSorted adds id to an ascending slice, new ids usually go at the end
+func insertSorted(ids []int, id int) []int {
+	if len(ids) == 0 || ids[len(ids)-1] < id {
+		return append(ids, id)
+	}
+
+	i, found := slices.BinarySearch(ids, id)
+	if found {
+		return ids
+	}
+	return slices.Insert(ids, i, id)
+}
+
+func removeSorted(ids []int, id int) []int {
+	i, found := slices.BinarySearch(ids, id)
+	if !found {
+		return ids
+	}
+	return slices.Delete(ids, i, i+1)
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIndexesTrackMutations(t *testing.T) {
+	db := InitCleanDB()
+
+	user, err := db.CreateUser("Someone@Example.com", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !db.IsEmailUsed("someone@example.COM") {
+		t.Error("expected email lookup to ignore case")
+	}
+
+	for _, body := range []string{"a", "b", "c"} {
+		_, err := db.CreateChirp(user.Id, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = db.CreateChirp(user.Id+1, "other author")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.DeleteChirp(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chirps, err := db.GetChirpsByUserID(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.Id)
+	}
+	if !slices.Equal(ids, []int{1, 3}) {
+		t.Errorf("expected author chirps [1 3], got %v", ids)
+	}
+
+	_, err = db.UpdateUser(user.Id, "new@example.com", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.IsEmailUsed("someone@example.com") || !db.IsEmailUsed("new@example.com") {
+		t.Error("expected email index to follow the update")
+	}
+
+	// Rebuilding from the stored state gives the same answers
+	db.index = buildIndexes(db.database)
+	if !slices.Equal(db.index.chirpsByAuthor[user.Id], []int{1, 3}) {
+		t.Errorf("rebuilt index differs, got %v", db.index.chirpsByAuthor[user.Id])
+	}
+}
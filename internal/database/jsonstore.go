@@ -0,0 +1,501 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// How long the writer waits for more mutations before appending a burst
+	jsonWriteDelay = 50 * time.Millisecond
+
+	// How often the log is compacted into a fresh snapshot
+	jsonSnapshotInterval = 5 * time.Minute
+
+	// Log length that triggers compaction ahead of schedule
+	jsonSnapshotRecords = 10000
+)
+
+// jsonStore keeps the Database as a JSON snapshot file plus an append-only
+// log of the mutations made since that snapshot, at the snapshot path with
+// a ".log" suffix.
+//
+// Mutations are queued in memory and a single writer goroutine appends them
+// to the log in bursts, so the cost of a write tracks the size of the change.
+// The writer periodically compacts the log into a new snapshot, which is
+// replaced atomically so a crash leaves either the old or the new file.
+type jsonStore struct {
+	path     string
+	logPath  string
+	mux      *sync.RWMutex
+	database *Database
+
+	wake    chan struct{}
+	changed chan struct{}
+	quit    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+
+	// Guards pending, err and known
+	state   sync.Mutex
+	pending []logRecord
+	err     error
+
+	// The snapshot as this store last read or wrote it
+	known fileStamp
+
+	// Called with the DB lock held after an external edit is loaded
+	onReload func(*Database)
+
+	// Only touched by the writer after Load
+	logged int
+}
+
+// newJSONStore shares the DB lock so snapshots marshal a consistent state
+func newJSONStore(path string, mux *sync.RWMutex) *jsonStore {
+	s := &jsonStore{
+		path:    path,
+		logPath: path + ".log",
+		mux:     mux,
+		wake:    make(chan struct{}, 1),
+		changed: make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.run()
+
+	return s
+}
+
+// Load reads the snapshot and replays the log on top of it, migrating both
+// from older schema versions
+func (s *jsonStore) Load() (*Database, error) {
+	original, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	dat, from, err := migrateJSON(original)
+	if err != nil {
+		return nil, err
+	}
+
+	database := newDatabase()
+	err = json.Unmarshal(dat, database)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := replayLog(s.logPath, database, from)
+	if err != nil {
+		return nil, err
+	}
+	s.logged = count
+	s.database = database
+	s.setKnown(stampFile(s.path))
+
+	if from < SchemaVersion {
+		// Keep the old file around and persist the migrated state
+		err = writeFileAtomic(fmt.Sprintf("%s.v%d.bak", s.path, from), original)
+		if err != nil {
+			return nil, err
+		}
+		err = s.compact(database)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return database, nil
+}
+
+func (s *jsonStore) SaveChirp(chirp Chirp) error {
+	return s.append(logRecord{Op: opSaveChirp, Chirp: &chirp})
+}
+
+func (s *jsonStore) RemoveChirp(id int) error {
+	return s.append(logRecord{Op: opRemoveChirp, Id: id})
+}
+
+func (s *jsonStore) SaveRevision(rev Revision) error {
+	return s.append(logRecord{Op: opSaveRevision, Revision: &rev})
+}
+
+func (s *jsonStore) SaveUser(user User) error {
+	return s.append(logRecord{Op: opSaveUser, User: &user})
+}
+
+func (s *jsonStore) SaveHash(userID int, hash []byte) error {
+	return s.append(logRecord{Op: opSaveHash, Id: userID, Hash: hash})
+}
+
+func (s *jsonStore) SaveRefreshToken(token string) error {
+	return s.append(logRecord{Op: opSaveRefreshToken, Token: token})
+}
+
+func (s *jsonStore) RemoveRefreshToken(token string) error {
+	return s.append(logRecord{Op: opRemoveRefreshToken, Token: token})
+}
+
+func (s *jsonStore) SaveRelation(rel Relation) error {
+	return s.append(logRecord{Op: opSaveRelation, Relation: &rel})
+}
+
+func (s *jsonStore) RemoveRelation(rel Relation) error {
+	return s.append(logRecord{Op: opRemoveRelation, Relation: &rel})
+}
+
+func (s *jsonStore) SaveMedia(m Media) error {
+	return s.append(logRecord{Op: opSaveMedia, Media: &m})
+}
+
+func (s *jsonStore) RemoveMedia(id int) error {
+	return s.append(logRecord{Op: opRemoveMedia, Id: id})
+}
+
+func (s *jsonStore) SaveDraft(d Draft) error {
+	return s.append(logRecord{Op: opSaveDraft, Draft: &d})
+}
+
+func (s *jsonStore) RemoveDraft(id int) error {
+	return s.append(logRecord{Op: opRemoveDraft, Id: id})
+}
+
+func (s *jsonStore) SaveList(l List) error {
+	return s.append(logRecord{Op: opSaveList, List: &l})
+}
+
+func (s *jsonStore) RemoveList(id int) error {
+	return s.append(logRecord{Op: opRemoveList, Id: id})
+}
+
+// Close stops the writer after compacting everything into the snapshot
+func (s *jsonStore) Close() error {
+	s.once.Do(func() {
+		close(s.quit)
+	})
+	<-s.stopped
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	return s.err
+}
+
+// append queues a record for the writer. Records that fail to write stay
+// queued and are retried with the next burst, and until a write succeeds
+// again the store is degraded: mutations are still queued but fail with
+// ErrStoreDegraded, since they may never reach the disk.
+func (s *jsonStore) append(rec logRecord) error {
+	s.state.Lock()
+	s.pending = append(s.pending, rec)
+	err := s.err
+	s.state.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStoreDegraded, err)
+	}
+	return nil
+}
+
+func (s *jsonStore) run() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(jsonSnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.wake:
+			// Coalesce a burst of mutations into one append
+			select {
+			case <-time.After(jsonWriteDelay):
+			case <-s.quit:
+				s.report(s.snapshot())
+				return
+			}
+			s.report(s.flushLog())
+			if s.logged >= jsonSnapshotRecords {
+				s.report(s.snapshot())
+			}
+		case <-ticker.C:
+			if s.logged > 0 {
+				s.report(s.snapshot())
+			}
+		case <-s.changed:
+			s.report(s.reloadExternal())
+		case <-s.quit:
+			s.report(s.snapshot())
+			return
+		}
+	}
+}
+
+func (s *jsonStore) report(err error) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.err = err
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+	}
+}
+
+// takePending removes the queued records
+func (s *jsonStore) takePending() []logRecord {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	recs := s.pending
+	s.pending = nil
+	return recs
+}
+
+// requeue puts records that failed to write back in front of the queue
+func (s *jsonStore) requeue(recs []logRecord) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.pending = append(recs, s.pending...)
+}
+
+// flushLog appends queued records to the log and syncs it
+func (s *jsonStore) flushLog() error {
+	recs := s.takePending()
+	if len(recs) == 0 {
+		return nil
+	}
+
+	err := s.writeLog(recs)
+	if err != nil {
+		s.requeue(recs)
+		return err
+	}
+	s.logged += len(recs)
+
+	return nil
+}
+
+func (s *jsonStore) writeLog(recs []logRecord) error {
+	dat, err := encodeRecords(recs)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(dat)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// snapshot writes the current state as the new snapshot and empties the log
+func (s *jsonStore) snapshot() error {
+	// Holding the read lock keeps mutations out, so the queued records are
+	// exactly the ones the marshalled state includes
+	s.mux.RLock()
+	if s.database == nil {
+		s.mux.RUnlock()
+		return nil
+	}
+	dat, err := json.MarshalIndent(s.database, "", "  ")
+	recs := s.takePending()
+	s.mux.RUnlock()
+	if err != nil {
+		s.requeue(recs)
+		return err
+	}
+
+	// Log first so a failed snapshot still leaves the mutations durable
+	if len(recs) > 0 {
+		err = s.writeLog(recs)
+		if err != nil {
+			s.requeue(recs)
+			return err
+		}
+		s.logged += len(recs)
+	}
+
+	return s.replace(dat)
+}
+
+// compact writes database as the snapshot and empties the log, for use
+// before the writer has started on the loaded state
+func (s *jsonStore) compact(database *Database) error {
+	dat, err := json.MarshalIndent(database, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return s.replace(dat)
+}
+
+// replace swaps in a new snapshot covering everything in the log
+func (s *jsonStore) replace(dat []byte) error {
+	err := writeFileAtomic(s.path, dat)
+	if err != nil {
+		return err
+	}
+
+	s.setKnown(stampFile(s.path))
+
+	// Replaying a stale log onto the new snapshot is harmless, so a crash
+	// before this truncate only costs replay time
+	err = os.Truncate(s.logPath, 0)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.logged = 0
+
+	return nil
+}
+
+// fileStamp identifies a version of a file by its size and modification time
+type fileStamp struct {
+	size int64
+	mod  time.Time
+}
+
+func stampFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{size: info.Size(), mod: info.ModTime()}
+}
+
+func (s *jsonStore) setKnown(stamp fileStamp) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.known = stamp
+}
+
+// watch polls the snapshot every interval and has the writer reload it when
+// another program edits it
+func (s *jsonStore) watch(interval time.Duration, onReload func(*Database)) {
+	s.mux.Lock()
+	s.onReload = onReload
+	s.mux.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				stamp := stampFile(s.path)
+
+				s.state.Lock()
+				edited := stamp != s.known
+				s.state.Unlock()
+
+				if edited {
+					select {
+					case s.changed <- struct{}{}:
+					default:
+					}
+				}
+			case <-s.quit:
+				return
+			}
+		}
+	}()
+}
+
+// reloadExternal loads an edited snapshot. Mutations not yet in a snapshot
+// are logged first so replaying the log keeps them on top of the edits.
+func (s *jsonStore) reloadExternal() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	err := s.flushLog()
+	if err != nil {
+		return err
+	}
+
+	// A half written file fails to parse, keep the current state and retry
+	// on the next poll. Only failed writes degrade the store.
+	database, err := s.Load()
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+		return nil
+	}
+
+	if s.onReload != nil {
+		s.onReload(database)
+	}
+	fmt.Printf("reloaded %s after external edit\n", s.path)
+
+	return nil
+}
+
+// writeFileAtomic replaces path with dat through a synced temp file and rename
+func writeFileAtomic(path string, dat []byte) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(dat)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+
+	// Persist the rename itself
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
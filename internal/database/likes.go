@@ -0,0 +1,155 @@
+package database
+
+import "time"
+
+// ChirpView is a chirp as shown to one viewer, with its counters
+type ChirpView struct {
+	Chirp
+
+	LikeCount    int `json:"like_count"`
+	RechirpCount int `json:"rechirp_count"`
+
+	// Only set when the viewer is signed in
+	LikedByMe      *bool `json:"liked_by_me,omitempty"`
+	BookmarkedByMe *bool `json:"bookmarked_by_me,omitempty"`
+
+	// The chirp quoted by QuoteOf
+	Quoted *QuotedChirp `json:"quoted,omitempty"`
+
+	// Replaces the stored poll of the chirp with its results
+	Poll *PollView `json:"poll,omitempty"`
+
+	// Set on placeholders for chirps hidden from the viewer
+	Unavailable bool `json:"unavailable,omitempty"`
+
+	// Set on the pinned chirps shown ahead of an author's chirps
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// QuotedChirp is a quoted chirp embedded one level deep. When the quoted
+// chirp was deleted, or a block stands between its author and the quoter or
+// viewer, it is a placeholder holding only the id.
+type QuotedChirp struct {
+	Id        int          `json:"id"`
+	AuthorId  int          `json:"author_id,omitempty"`
+	Body      string       `json:"body,omitempty"`
+	CreatedAt *time.Time   `json:"created_at,omitempty"`
+	Edited    bool         `json:"edited,omitempty"`
+	Media     []Attachment `json:"media,omitempty"`
+
+	Deleted     bool `json:"deleted"`
+	Unavailable bool `json:"unavailable"`
+}
+
+// Like records that userID likes a chirp, liking twice is a no-op
+func (db *DB) Like(userID int, chirpID int) (ChirpView, error) {
+	return db.setChirpRelation(RelationLike, userID, chirpID, true)
+}
+
+func (db *DB) Unlike(userID int, chirpID int) (ChirpView, error) {
+	return db.setChirpRelation(RelationLike, userID, chirpID, false)
+}
+
+// Rechirp records that userID shared a chirp, rechirping twice is a no-op
+func (db *DB) Rechirp(userID int, chirpID int) (ChirpView, error) {
+	return db.setChirpRelation(RelationRechirp, userID, chirpID, true)
+}
+
+func (db *DB) Unrechirp(userID int, chirpID int) (ChirpView, error) {
+	return db.setChirpRelation(RelationRechirp, userID, chirpID, false)
+}
+
+// setChirpRelation adds or removes an edge from a user to a live chirp,
+// returning the chirp with its updated counters
+func (db *DB) setChirpRelation(kind string, userID int, chirpID int, on bool) (ChirpView, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.database.Chirps[chirpID]
+	if !ok || !db.filterFor(userID).shown(chirp) {
+		return ChirpView{}, ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return ChirpView{}, ErrChirpDeleted
+	}
+	// Rechirps would put a chirp in front of readers it isn't meant for
+	if kind == RelationRechirp && on && chirp.Visibility != VisibilityPublic {
+		return ChirpView{}, ErrNotPublic
+	}
+
+	var err error
+	if on {
+		_, err = db.addRelation(kind, userID, chirpID)
+	} else {
+		_, err = db.removeRelation(kind, userID, chirpID)
+	}
+	if err != nil {
+		return ChirpView{}, err
+	}
+
+	return db.viewChirp(chirp, userID), nil
+}
+
+// ViewChirps adds counters to chirps for viewerID, 0 for an anonymous viewer
+func (db *DB) ViewChirps(chirps []Chirp, viewerID int) []ChirpView {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	views := make([]ChirpView, 0, len(chirps))
+	for _, chirp := range chirps {
+		views = append(views, db.viewChirp(chirp, viewerID))
+	}
+
+	return views
+}
+
+func (db *DB) ViewChirp(chirp Chirp, viewerID int) ChirpView {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.viewChirp(chirp, viewerID)
+}
+
+// viewChirp must hold the lock
+func (db *DB) viewChirp(chirp Chirp, viewerID int) ChirpView {
+	view := ChirpView{
+		Chirp:        chirp,
+		LikeCount:    len(db.index.relationsTo.get(RelationLike, chirp.Id)),
+		RechirpCount: len(db.index.relationsTo.get(RelationRechirp, chirp.Id)),
+	}
+	if viewerID != 0 {
+		liked := db.hasRelation(RelationLike, viewerID, chirp.Id)
+		view.LikedByMe = &liked
+		bookmarked := db.hasRelation(RelationBookmark, viewerID, chirp.Id)
+		view.BookmarkedByMe = &bookmarked
+	}
+	if chirp.QuoteOf != 0 {
+		view.Quoted = db.quotedChirp(chirp.QuoteOf, chirp.AuthorId, viewerID)
+	}
+	if chirp.Poll != nil {
+		view.Poll = db.viewPoll(chirp, viewerID)
+	}
+
+	return view
+}
+
+// quotedChirp embeds the chirp quoted by quoterID as seen by viewerID, must
+// hold the lock
+func (db *DB) quotedChirp(id int, quoterID int, viewerID int) *QuotedChirp {
+	quoted, ok := db.database.Chirps[id]
+	if !ok || quoted.DeletedAt != nil {
+		return &QuotedChirp{Id: id, Deleted: true, Unavailable: true}
+	}
+	if db.hasRelation(RelationBlock, quoted.AuthorId, quoterID) || !db.filterFor(viewerID).shown(quoted) {
+		return &QuotedChirp{Id: id, Unavailable: true}
+	}
+
+	return &QuotedChirp{
+		Id:        quoted.Id,
+		AuthorId:  quoted.AuthorId,
+		Body:      quoted.Body,
+		CreatedAt: &quoted.CreatedAt,
+		Edited:    quoted.Edited,
+		Media:     quoted.Media,
+	}
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestLikesAndRechirps(t *testing.T) {
+	db := InitCleanDB()
+	chirp, _ := db.CreateChirp(1, "likeable")
+
+	view, err := db.Like(2, chirp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view, _ = db.Like(2, chirp.Id)
+	if view.LikeCount != 1 || view.LikedByMe == nil || !*view.LikedByMe {
+		t.Errorf("expected one like by the caller, got %+v", view)
+	}
+
+	db.Rechirp(3, chirp.Id)
+	views := db.ViewChirps([]Chirp{chirp}, 0)
+	if views[0].RechirpCount != 1 || views[0].LikedByMe != nil {
+		t.Errorf("expected one rechirp and no liked_by_me, got %+v", views[0])
+	}
+
+	view, _ = db.Unlike(2, chirp.Id)
+	view, _ = db.Unlike(2, chirp.Id)
+	if view.LikeCount != 0 || *view.LikedByMe {
+		t.Errorf("expected no likes, got %+v", view)
+	}
+
+	_, err = db.Like(2, 99)
+	if !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("expected ErrChirpNotFound, got %v", err)
+	}
+}
+
+func TestConcurrentLikes(t *testing.T) {
+	db := InitCleanDB()
+	chirp, _ := db.CreateChirp(1, "popular")
+
+	wg := sync.WaitGroup{}
+	for user := 1; user <= 50; user++ {
+		wg.Add(1)
+		go func(user int) {
+			defer wg.Done()
+			db.Like(user, chirp.Id)
+			db.Like(user, chirp.Id)
+			db.Rechirp(user, chirp.Id)
+		}(user)
+	}
+	wg.Wait()
+
+	view := db.ViewChirp(chirp, 0)
+	if view.LikeCount != 50 || view.RechirpCount != 50 {
+		t.Errorf("expected 50 likes and rechirps, got %d and %d", view.LikeCount, view.RechirpCount)
+	}
+
+	// Purging the chirp drops its likes
+	db.DeleteChirp(chirp.Id)
+	db.purgeChirp(db.database.Chirps[chirp.Id])
+	if len(db.database.Relations) != 0 {
+		t.Errorf("expected relations purged, got %d", len(db.database.Relations))
+	}
+}
@@ -0,0 +1,245 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Longest list name and description, in runes
+	MaxListNameLength        = 25
+	MaxListDescriptionLength = 100
+
+	// Most users a list can hold
+	MaxListMembers = 500
+)
+
+var ErrListNotFound error = errors.New("list not found")
+var ErrBadListName error = errors.New("list name must be 1 to 25 characters")
+var ErrListDescriptionTooLong error = errors.New("list description is too long")
+var ErrListFull error = errors.New("list has too many members")
+
+// List is a named set of users curated by its owner, with a timeline of
+// their chirps. Private lists are seen only by their owner.
+type List struct {
+	Id        int       `json:"id"`
+	OwnerId   int       `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ListParams
+}
+
+// ListParams are the editable parts of a list
+type ListParams struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Private     bool   `json:"private"`
+}
+
+func (p ListParams) check() (ListParams, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	n := utf8.RuneCountInString(p.Name)
+	if n == 0 || n > MaxListNameLength {
+		return p, ErrBadListName
+	}
+	if utf8.RuneCountInString(p.Description) > MaxListDescriptionLength {
+		return p, ErrListDescriptionTooLong
+	}
+	return p, nil
+}
+
+func (db *DB) CreateList(ownerID int, params ListParams) (List, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	params, err := params.check()
+	if err != nil {
+		return List{}, err
+	}
+
+	now := time.Now().UTC()
+	l := List{
+		Id:         db.database.NextLID,
+		OwnerId:    ownerID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		ListParams: params,
+	}
+	db.database.NextLID++
+	db.database.Lists[l.Id] = l
+	db.index.listsByOwner[ownerID] = insertSorted(db.index.listsByOwner[ownerID], l.Id)
+
+	return l, db.store.SaveList(l)
+}
+
+// GetList gets a list viewerID can see, 0 for an anonymous viewer
+func (db *DB) GetList(id int, viewerID int) (List, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.visibleList(id, viewerID)
+}
+
+// GetLists lists the lists of ownerID that viewerID can see, oldest first
+func (db *DB) GetLists(ownerID int, viewerID int) ([]List, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.database.Users[ownerID]; !ok {
+		return nil, ErrUserNotFound
+	}
+
+	ids := db.index.listsByOwner[ownerID]
+	lists := make([]List, 0, len(ids))
+	for _, id := range ids {
+		l := db.database.Lists[id]
+		if !l.Private || ownerID == viewerID {
+			lists = append(lists, l)
+		}
+	}
+
+	return lists, nil
+}
+
+// UpdateList replaces the name, description and privacy of a list
+func (db *DB) UpdateList(id int, ownerID int, params ListParams) (List, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	l, err := db.ownedList(id, ownerID)
+	if err != nil {
+		return List{}, err
+	}
+	params, err = params.check()
+	if err != nil {
+		return List{}, err
+	}
+
+	l.ListParams = params
+	l.UpdatedAt = time.Now().UTC()
+	db.database.Lists[id] = l
+
+	return l, db.store.SaveList(l)
+}
+
+func (db *DB) DeleteList(id int, ownerID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	l, err := db.ownedList(id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range slices.Clone(db.index.relationsFrom.get(RelationListMember, id)) {
+		_, err = db.removeRelation(RelationListMember, id, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(db.database.Lists, id)
+	removeKeyed(db.index.listsByOwner, l.OwnerId, id)
+	return db.store.RemoveList(id)
+}
+
+// AddListMember adds userID to a list, adding twice is a no-op. Users
+// blocking or blocked by the owner can't be added.
+func (db *DB) AddListMember(id int, ownerID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	_, err := db.ownedList(id, ownerID)
+	if err != nil {
+		return err
+	}
+	if _, ok := db.database.Users[userID]; !ok {
+		return ErrUserNotFound
+	}
+	if db.blocked(ownerID, userID) {
+		return ErrBlocked
+	}
+	if db.hasRelation(RelationListMember, id, userID) {
+		return nil
+	}
+	if len(db.index.relationsFrom.get(RelationListMember, id)) >= MaxListMembers {
+		return ErrListFull
+	}
+
+	_, err = db.addRelation(RelationListMember, id, userID)
+	return err
+}
+
+// RemoveListMember takes userID off a list, a no-op if they weren't on it
+func (db *DB) RemoveListMember(id int, ownerID int, userID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	_, err := db.ownedList(id, ownerID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.removeRelation(RelationListMember, id, userID)
+	return err
+}
+
+// GetListMembers pages through the members of a list by user id
+func (db *DB) GetListMembers(id int, viewerID int, q ChirpQuery) (UserPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	_, err := db.visibleList(id, viewerID)
+	if err != nil {
+		return UserPage{}, err
+	}
+
+	return db.pageUsers(db.index.relationsFrom.get(RelationListMember, id), q), nil
+}
+
+// GetListTimeline returns a page of chirps by the members of a list, as
+// viewerID is allowed to see them
+func (db *DB) GetListTimeline(id int, viewerID int, q ChirpQuery) (ChirpPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	_, err := db.visibleList(id, viewerID)
+	if err != nil {
+		return ChirpPage{}, err
+	}
+
+	q.ViewerID = viewerID
+	members := db.index.relationsFrom.get(RelationListMember, id)
+	lists := make([][]int, 0, len(members))
+	for _, userID := range members {
+		lists = append(lists, db.index.chirpsByAuthor[userID])
+	}
+
+	return db.pageChirps(db.mergeWindows(lists, q), q), nil
+}
+
+// visibleList gets a list unless it is another user's private list, must
+// hold the lock
+func (db *DB) visibleList(id int, viewerID int) (List, error) {
+	l, ok := db.database.Lists[id]
+	if !ok || (l.Private && l.OwnerId != viewerID) {
+		return List{}, ErrListNotFound
+	}
+	return l, nil
+}
+
+// ownedList gets a list userID can edit, must hold the lock
+func (db *DB) ownedList(id int, userID int) (List, error) {
+	l, err := db.visibleList(id, userID)
+	if err != nil {
+		return List{}, err
+	}
+	if l.OwnerId != userID {
+		return List{}, ErrNotAuthorized
+	}
+	return l, nil
+}
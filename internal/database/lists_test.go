@@ -0,0 +1,85 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestListTimeline(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+	carol, _ := db.CreateUserWith(UserParams{Email: "c@x.y", Password: "pw", Handle: "carol"})
+
+	list, err := db.CreateList(alice.Id, ListParams{Name: " friends ", Private: true})
+	if err != nil || list.Name != "friends" {
+		t.Fatalf("unexpected list %v, %v", list, err)
+	}
+	db.AddListMember(list.Id, alice.Id, bob.Id)
+
+	first, _ := db.CreateChirp(bob.Id, "from bob")
+	db.CreateChirp(carol.Id, "from carol")
+	page, err := db.GetListTimeline(list.Id, alice.Id, ChirpQuery{})
+	if err != nil || len(page.Chirps) != 1 || page.Chirps[0].Id != first.Id {
+		t.Errorf("expected only bob's chirp, got %v, %v", page.Chirps, err)
+	}
+
+	// Private lists are hidden from everyone but the owner
+	if _, err := db.GetListTimeline(list.Id, bob.Id, ChirpQuery{}); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected ErrListNotFound, got %v", err)
+	}
+	if lists, _ := db.GetLists(alice.Id, 0); len(lists) != 0 {
+		t.Errorf("expected private list to be hidden, got %v", lists)
+	}
+
+	// Public lists can be read but only edited by the owner
+	db.UpdateList(list.Id, alice.Id, ListParams{Name: "friends"})
+	if _, err := db.GetList(list.Id, 0); err != nil {
+		t.Errorf("expected public list to be readable, got %v", err)
+	}
+	if err := db.AddListMember(list.Id, bob.Id, carol.Id); !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("expected ErrNotAuthorized, got %v", err)
+	}
+	if _, err := db.UpdateList(list.Id, bob.Id, ListParams{Name: "mine"}); !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("expected ErrNotAuthorized, got %v", err)
+	}
+
+	err = db.DeleteList(list.Id, alice.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetList(list.Id, alice.Id); !errors.Is(err, ErrListNotFound) {
+		t.Errorf("expected list to be gone, got %v", err)
+	}
+	if db.hasRelation(RelationListMember, list.Id, bob.Id) {
+		t.Error("expected members to be removed with the list")
+	}
+}
+
+func TestBookmarks(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+
+	kept, _ := db.CreateChirp(bob.Id, "keep this")
+	trashed, _ := db.CreateChirp(bob.Id, "and this")
+	view, err := db.Bookmark(alice.Id, kept.Id)
+	if err != nil || view.BookmarkedByMe == nil || !*view.BookmarkedByMe {
+		t.Fatalf("expected bookmark, got %+v, %v", view, err)
+	}
+	db.Bookmark(alice.Id, trashed.Id)
+	db.DeleteChirp(trashed.Id)
+
+	page, _ := db.GetBookmarks(alice.Id, ChirpQuery{})
+	if len(page.Chirps) != 1 || page.Chirps[0].Id != kept.Id {
+		t.Errorf("expected the kept chirp, got %v", page.Chirps)
+	}
+	if page, _ := db.GetBookmarks(bob.Id, ChirpQuery{}); len(page.Chirps) != 0 {
+		t.Errorf("expected bookmarks to be private, got %v", page.Chirps)
+	}
+
+	db.Unbookmark(alice.Id, kept.Id)
+	if page, _ := db.GetBookmarks(alice.Id, ChirpQuery{}); len(page.Chirps) != 0 {
+		t.Errorf("expected no bookmarks, got %v", page.Chirps)
+	}
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// Most images a chirp can show on the free plan
+	MaxChirpMedia = 4
+
+	// Longest alt text, in runes
+	MaxAltLength = 1000
+)
+
+var ErrMediaNotFound error = errors.New("media not found")
+var ErrTooManyMedia error = errors.New("chirp has too many media attachments")
+var ErrDuplicateMedia error = errors.New("chirp attaches the same media twice")
+var ErrAltTooLong error = errors.New("alt text is too long")
+
+// Media is an uploaded image. Files are stored by the hash of their content,
+// so uploads of the same image share them.
+type Media struct {
+	Id          int       `json:"id"`
+	OwnerId     int       `json:"owner_id"`
+	Hash        string    `json:"hash"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+
+	ThumbnailContentType string `json:"thumbnail_content_type"`
+	ThumbnailWidth       int    `json:"thumbnail_width"`
+	ThumbnailHeight      int    `json:"thumbnail_height"`
+	ThumbnailURL         string `json:"thumbnail_url"`
+}
+
+// Attachment is an image shown on a chirp
+type Attachment struct {
+	Id           int    `json:"id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Alt          string `json:"alt"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// AttachmentParams pick one of the author's uploads for a new chirp
+type AttachmentParams struct {
+	Id  int    `json:"id"`
+	Alt string `json:"alt"`
+}
+
+// CreateMedia records an upload. Uploading the same image again returns the
+// earlier record, with its garbage collection grace started over.
+func (db *DB) CreateMedia(m Media) (Media, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	now := time.Now().UTC()
+	for _, id := range db.index.mediaByHash[m.Hash] {
+		existing := db.database.Media[id]
+		if existing.OwnerId == m.OwnerId {
+			existing.CreatedAt = now
+			db.database.Media[id] = existing
+			return existing, db.store.SaveMedia(existing)
+		}
+	}
+
+	m.Id = db.database.NextMID
+	m.CreatedAt = now
+	db.database.NextMID++
+	db.database.Media[m.Id] = m
+	db.index.mediaByHash[m.Hash] = insertSorted(db.index.mediaByHash[m.Hash], m.Id)
+
+	return m, db.store.SaveMedia(m)
+}
+
+func (db *DB) GetMedia(id int) (Media, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	m, ok := db.database.Media[id]
+	if !ok {
+		return Media{}, ErrMediaNotFound
+	}
+	return m, nil
+}
+
+// attachments checks the uploads picked for a new chirp by authorID, at most
+// limit of them. Must hold the lock.
+func (db *DB) attachments(authorID int, params []AttachmentParams, limit int) ([]Attachment, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+	if len(params) > limit {
+		return nil, ErrTooManyMedia
+	}
+
+	media := make([]Attachment, 0, len(params))
+	seen := map[int]bool{}
+	for _, p := range params {
+		m, ok := db.database.Media[p.Id]
+		if !ok || m.OwnerId != authorID {
+			return nil, ErrMediaNotFound
+		}
+		if seen[p.Id] {
+			return nil, ErrDuplicateMedia
+		}
+		if utf8.RuneCountInString(p.Alt) > MaxAltLength {
+			return nil, ErrAltTooLong
+		}
+		seen[p.Id] = true
+
+		media = append(media, Attachment{
+			Id:           m.Id,
+			Width:        m.Width,
+			Height:       m.Height,
+			Alt:          p.Alt,
+			URL:          m.URL,
+			ThumbnailURL: m.ThumbnailURL,
+		})
+	}
+
+	return media, nil
+}
+
+// CollectMedia removes uploads no chirp or draft shows once they are older
+// than grace, so unposted uploads have time to be attached. Media in the
+// trash stays until the chirp is purged. It returns the removed media whose
+// files no other upload shares, for the caller to delete. Callers must keep
+// new uploads of those files from being saved until they are deleted.
+func (db *DB) CollectMedia(grace time.Duration) ([]Media, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	cutoff := time.Now().Add(-grace)
+	drafted := db.draftMedia()
+	removed := []Media{}
+	for id, m := range db.database.Media {
+		if db.index.mediaRefs[id] > 0 || drafted[id] || m.CreatedAt.After(cutoff) {
+			continue
+		}
+
+		delete(db.database.Media, id)
+		removeKeyed(db.index.mediaByHash, m.Hash, id)
+		err := db.store.RemoveMedia(id)
+		if err != nil {
+			return nil, err
+		}
+		removed = append(removed, m)
+	}
+
+	// Keep files still used by other uploads
+	orphans := []Media{}
+	seen := map[string]bool{}
+	for _, m := range removed {
+		if len(db.index.mediaByHash[m.Hash]) == 0 && !seen[m.Hash] {
+			seen[m.Hash] = true
+			orphans = append(orphans, m)
+		}
+	}
+
+	return orphans, nil
+}
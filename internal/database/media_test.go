@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMediaAttachments(t *testing.T) {
+	db := InitCleanDB()
+
+	m, err := db.CreateMedia(Media{OwnerId: 1, Hash: "aa", Width: 640, Height: 480, URL: "/m/aa.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := db.CreateMedia(Media{OwnerId: 1, Hash: "aa"})
+	if again.Id != m.Id {
+		t.Errorf("expected the same upload back, got %d and %d", m.Id, again.Id)
+	}
+
+	chirp, err := db.CreateChirpWith(1, ChirpParams{Body: "look", Media: []AttachmentParams{{Id: m.Id, Alt: "a cat"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chirp.Media) != 1 || chirp.Media[0].Width != 640 || chirp.Media[0].Alt != "a cat" || chirp.Media[0].URL != "/m/aa.png" {
+		t.Errorf("unexpected attachments %+v", chirp.Media)
+	}
+
+	// Other people's uploads can't be attached
+	_, err = db.CreateChirpWith(2, ChirpParams{Media: []AttachmentParams{{Id: m.Id}}})
+	if !errors.Is(err, ErrMediaNotFound) {
+		t.Errorf("expected ErrMediaNotFound, got %v", err)
+	}
+	_, err = db.CreateChirpWith(1, ChirpParams{Media: []AttachmentParams{{Id: m.Id}, {Id: m.Id}}})
+	if !errors.Is(err, ErrDuplicateMedia) {
+		t.Errorf("expected ErrDuplicateMedia, got %v", err)
+	}
+	_, err = db.CreateChirpWith(1, ChirpParams{Media: make([]AttachmentParams, MaxChirpMedia+1)})
+	if !errors.Is(err, ErrTooManyMedia) {
+		t.Errorf("expected ErrTooManyMedia, got %v", err)
+	}
+}
+
+func TestCollectMedia(t *testing.T) {
+	db := InitCleanDB()
+
+	used, _ := db.CreateMedia(Media{OwnerId: 1, Hash: "aa"})
+	unused, _ := db.CreateMedia(Media{OwnerId: 1, Hash: "bb"})
+	shared, _ := db.CreateMedia(Media{OwnerId: 2, Hash: "aa"})
+	db.CreateMedia(Media{OwnerId: 3, Hash: "cc"})
+	chirp, _ := db.CreateChirpWith(1, ChirpParams{Body: "pic", Media: []AttachmentParams{{Id: used.Id}}})
+
+	// Fresh uploads are kept for the grace period
+	collected, err := db.CollectMedia(time.Hour)
+	if err != nil || len(collected) != 0 {
+		t.Fatalf("expected nothing collected, got %v %v", collected, err)
+	}
+
+	collected, _ = db.CollectMedia(0)
+	if _, err := db.GetMedia(unused.Id); !errors.Is(err, ErrMediaNotFound) {
+		t.Errorf("expected unattached upload to be collected")
+	}
+	if _, err := db.GetMedia(shared.Id); !errors.Is(err, ErrMediaNotFound) {
+		t.Errorf("expected unattached copy to be collected")
+	}
+	if _, err := db.GetMedia(used.Id); err != nil {
+		t.Errorf("expected attached upload to stay, got %v", err)
+	}
+
+	// Files of aa are still used by the attached upload
+	hashes := map[string]bool{}
+	for _, m := range collected {
+		hashes[m.Hash] = true
+	}
+	if len(collected) != 2 || !hashes["bb"] || !hashes["cc"] {
+		t.Errorf("expected files of bb and cc to be freed, got %+v", collected)
+	}
+
+	// Media in the trash stays until the chirp is purged
+	db.DeleteChirp(chirp.Id)
+	db.CollectMedia(0)
+	if _, err := db.GetMedia(used.Id); err != nil {
+		t.Errorf("expected trashed chirp to keep its media, got %v", err)
+	}
+	db.SetTrashRetention(0)
+	db.PurgeExpired()
+	collected, _ = db.CollectMedia(0)
+	if len(collected) != 1 || collected[0].Id != used.Id {
+		t.Errorf("expected media of purged chirp to be collected, got %+v", collected)
+	}
+	// Uploading an old image again starts its grace over
+	old, _ := db.CreateMedia(Media{OwnerId: 1, Hash: "dd"})
+	m := db.database.Media[old.Id]
+	m.CreatedAt = m.CreatedAt.Add(-2 * time.Hour)
+	db.database.Media[old.Id] = m
+	again, _ := db.CreateMedia(Media{OwnerId: 1, Hash: "dd"})
+	collected, _ = db.CollectMedia(time.Hour)
+	if again.Id != old.Id || len(collected) != 0 {
+		t.Errorf("expected re-upload to keep %d, got %d and collected %+v", old.Id, again.Id, collected)
+	}
+}
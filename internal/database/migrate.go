@@ -0,0 +1,354 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the Database format this build reads and writes
+const SchemaVersion = 6
+
+// Migration upgrades a raw Database document from version From to From+1.
+//
+// Documents are the JSON form of Database decoded into maps, so migrations
+// can rename or reshape fields the current structs no longer have. Records
+// replayed from a write-ahead log are migrated as documents holding only that
+// record, so Up must tolerate missing collections.
+type Migration struct {
+	From        int
+	Description string
+	Up          func(doc map[string]any) error
+}
+
+// migrations are applied in order, migrations[i] upgrades version i
+var migrations = []Migration{
+	{
+		From:        0,
+		Description: "add schema version",
+		Up:          func(doc map[string]any) error { return nil },
+	},
+	{
+		From:        1,
+		Description: "add chirp timestamps and edited flag",
+		Up: func(doc map[string]any) error {
+			// Creation times were never recorded. Step back a second per id
+			// from the time of migration, so times still ascend with ids as
+			// the created_after and created_before bounds rely on.
+			chirps := docRecords(doc, "chirps")
+			maxID := 0.0
+			for _, chirp := range chirps {
+				id, _ := chirp["id"].(float64)
+				maxID = max(maxID, id)
+			}
+			now := time.Now().UTC()
+			for _, chirp := range chirps {
+				id, _ := chirp["id"].(float64)
+				at := now.Add(-time.Duration(maxID-id) * time.Second).Format(time.RFC3339Nano)
+				setDefault(chirp, "created_at", at)
+				setDefault(chirp, "updated_at", at)
+				setDefault(chirp, "edited", false)
+			}
+			return nil
+		},
+	},
+	{
+		From:        2,
+		Description: "add user handles and profiles",
+		Up: func(doc map[string]any) error {
+			// No handles existed before, so the defaults can't collide
+			for _, user := range docRecords(doc, "users") {
+				id, _ := user["id"].(float64)
+				setDefault(user, "handle", fmt.Sprintf("user%d", int(id)))
+				setDefault(user, "display_name", "")
+				setDefault(user, "bio", "")
+			}
+			return nil
+		},
+	},
+	{
+		From:        3,
+		Description: "add chirp visibility",
+		Up: func(doc map[string]any) error {
+			for _, chirp := range docRecords(doc, "chirps") {
+				setDefault(chirp, "visibility", "public")
+			}
+			return nil
+		},
+	},
+	{
+		From:        4,
+		Description: "add user plans",
+		Up: func(doc map[string]any) error {
+			for _, user := range docRecords(doc, "users") {
+				plan := "free"
+				if red, _ := user["is_chirpy_red"].(bool); red {
+					plan = "red"
+				}
+				setDefault(user, "plan", plan)
+			}
+			return nil
+		},
+	},
+	{
+		From:        5,
+		Description: "resolve chirp mentions to user ids",
+		Up: func(doc map[string]any) error {
+			// Chirps migrated without their users keep no mentions
+			handles := map[string]float64{}
+			for _, user := range docRecords(doc, "users") {
+				handle, _ := user["handle"].(string)
+				id, _ := user["id"].(float64)
+				if handle != "" {
+					handles[handleKey(handle)] = id
+				}
+			}
+			for _, chirp := range docRecords(doc, "chirps") {
+				body, _ := chirp["body"].(string)
+				mentions := []any{}
+				for _, handle := range ExtractMentions(body) {
+					if id, ok := handles[handle]; ok && !slices.Contains(mentions, any(id)) {
+						mentions = append(mentions, id)
+					}
+				}
+				setDefault(chirp, "mentions", mentions)
+			}
+			return nil
+		},
+	},
+}
+
+// docRecords returns the records of a collection in a raw document
+func docRecords(doc map[string]any, collection string) []map[string]any {
+	records := []map[string]any{}
+	m, ok := doc[collection].(map[string]any)
+	if !ok {
+		return records
+	}
+
+	for _, v := range m {
+		record, ok := v.(map[string]any)
+		if ok {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func setDefault(record map[string]any, key string, value any) {
+	if _, ok := record[key]; !ok {
+		record[key] = value
+	}
+}
+
+type MigrationResult struct {
+	From    int
+	To      int
+	Applied []string
+	Changes []string
+}
+
+// docVersion reads the schema version of a raw document, files written
+// before versioning count as version 0
+func docVersion(doc map[string]any) int {
+	v, ok := doc["version"].(float64)
+	if !ok {
+		return 0
+	}
+	return int(v)
+}
+
+// migrateDoc upgrades doc in place to SchemaVersion, returning the
+// descriptions of the migrations applied
+func migrateDoc(doc map[string]any) ([]string, error) {
+	version := docVersion(doc)
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("database version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	applied := []string{}
+	for _, m := range migrations[version:] {
+		if m.From != version {
+			return nil, fmt.Errorf("migration for version %d registered at %d", m.From, version)
+		}
+
+		err := m.Up(doc)
+		if err != nil {
+			return nil, fmt.Errorf("migrating version %d: %w", version, err)
+		}
+		version++
+		doc["version"] = version
+		applied = append(applied, fmt.Sprintf("%d -> %d: %s", m.From, version, m.Description))
+	}
+
+	return applied, nil
+}
+
+// migrateJSON upgrades an encoded Database, returning the version it started at
+func migrateJSON(dat []byte) ([]byte, int, error) {
+	header := struct {
+		Version int `json:"version"`
+	}{}
+	err := json.Unmarshal(dat, &header)
+	if err != nil {
+		return nil, 0, err
+	}
+	if header.Version == SchemaVersion {
+		return dat, header.Version, nil
+	}
+
+	doc := map[string]any{}
+	err = json.Unmarshal(dat, &doc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, err = migrateDoc(doc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dat, err = json.Marshal(doc)
+	return dat, header.Version, err
+}
+
+// migrateRecord upgrades a single record of collection, such as a chirp
+// from the write-ahead log, written at version from
+func migrateRecord(collection string, record json.RawMessage, from int) (json.RawMessage, error) {
+	if from == SchemaVersion || len(record) == 0 {
+		return record, nil
+	}
+
+	var v any
+	err := json.Unmarshal(record, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]any{
+		"version":  from,
+		collection: map[string]any{"0": v},
+	}
+	_, err = migrateDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc[collection].(map[string]any)["0"])
+}
+
+// MigrateFile upgrades the JSON database at path and its log to
+// SchemaVersion. The result lists the changes made to the snapshot, with
+// dryRun the files are left untouched.
+func MigrateFile(path string, dryRun bool) (MigrationResult, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+
+	before := map[string]any{}
+	err = json.Unmarshal(dat, &before)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+
+	after := map[string]any{}
+	json.Unmarshal(dat, &after)
+
+	applied, err := migrateDoc(after)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+
+	result := MigrationResult{
+		From:    docVersion(before),
+		To:      SchemaVersion,
+		Applied: applied,
+		Changes: DiffDocs(before, after),
+	}
+
+	if dryRun || len(applied) == 0 {
+		return result, nil
+	}
+
+	// Loading migrates and compacts the snapshot and log
+	db, err := InitDB(nil, path)
+	if err != nil {
+		return result, err
+	}
+
+	return result, db.Close()
+}
+
+// DiffDocs lists the differences between two raw documents, one line per
+// changed value prefixed with - or + and its dotted path
+func DiffDocs(before, after map[string]any) []string {
+	lines := []string{}
+	diffValues("", before, after, &lines)
+	return lines
+}
+
+func diffValues(path string, before, after any, lines *[]string) {
+	bm, bok := before.(map[string]any)
+	am, aok := after.(map[string]any)
+	if bok && aok {
+		keys := []string{}
+		for k := range bm {
+			keys = append(keys, k)
+		}
+		for k := range am {
+			if _, ok := bm[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sortKeys(keys)
+
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			diffValues(child, bm[k], am[k], lines)
+		}
+		return
+	}
+
+	if reflect.DeepEqual(before, after) {
+		return
+	}
+	if before != nil {
+		*lines = append(*lines, fmt.Sprintf("- %s: %s", path, encodeValue(before)))
+	}
+	if after != nil {
+		*lines = append(*lines, fmt.Sprintf("+ %s: %s", path, encodeValue(after)))
+	}
+}
+
+// sortKeys orders numeric keys such as ids numerically, then the rest
+func sortKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if len(a) != len(b) && isDigits(a) && isDigits(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+}
+
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func encodeValue(v any) string {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(dat)
+}
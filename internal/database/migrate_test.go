@@ -0,0 +1,61 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	old := `{"nextuid": 2, "nextcid": 4, "users": {"1": {"id": 1, "email": "a@x.y"}}, "chirps": {"1": {"body": "hi @user1", "id": 1, "author_id": 1}, "3": {"body": "later", "id": 3, "author_id": 1}}}`
+	err := os.WriteFile(path, []byte(old), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := MigrateFile(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.From != 0 || result.To != SchemaVersion || len(result.Changes) == 0 {
+		t.Errorf("unexpected dry run result %+v", result)
+	}
+
+	dat, _ := os.ReadFile(path)
+	if string(dat) != old {
+		t.Error("dry run changed the file")
+	}
+
+	_, err = MigrateFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if db.database.Version != SchemaVersion {
+		t.Errorf("expected version %d, got %d", SchemaVersion, db.database.Version)
+	}
+	if page, _ := db.GetMentions(1, ChirpQuery{}); len(page.Chirps) != 1 {
+		t.Errorf("expected the mention of user1 resolved, got %v", pageIDs(page))
+	}
+	// Backfilled times ascend with ids, so time bounds still page correctly
+	first, _ := db.GetChirp(1)
+	page, _ := db.GetChirpsPage(ChirpQuery{CreatedAfter: first.CreatedAt})
+	if !first.CreatedAt.Before(db.database.Chirps[3].CreatedAt) || len(page.Chirps) != 1 || page.Chirps[0].Id != 3 {
+		t.Errorf("expected backfilled times in id order, got %v", pageIDs(page))
+	}
+	if _, err := os.Stat(path + ".v0.bak"); err != nil {
+		t.Errorf("expected backup of the old file, %s", err)
+	}
+
+	result, err = MigrateFile(path, true)
+	if err != nil || len(result.Applied) != 0 {
+		t.Errorf("expected nothing left to migrate, got %+v, %v", result, err)
+	}
+}
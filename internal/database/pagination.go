@@ -0,0 +1,204 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+var ErrBadCursor error = errors.New("bad cursor")
+
+// ChirpQuery selects a page of chirps by id
+type ChirpQuery struct {
+	// Only chirps by this author, 0 for everyone
+	AuthorID int
+
+	// Newest first
+	Desc bool
+
+	// Page size, clamped to MaxPageLimit, DefaultPageLimit when 0
+	Limit int
+
+	// Resume after this chirp id, from ChirpPage.Next
+	After int
+
+	// Only chirps with id > SinceID and id <= MaxID, 0 for no bound
+	SinceID int
+	MaxID   int
+
+	// Only chirps created after/before these times, zero for no bound
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	// Leaves out chirps hidden from this user by blocks, mutes and visibility,
+	// 0 for an anonymous viewer
+	ViewerID int
+}
+
+type ChirpPage struct {
+	Chirps []Chirp
+
+	// Cursor for the following page, 0 when this is the last
+	Next int
+}
+
+// GetChirpsPage returns one page of chirps ordered by id, walking the id
+// indexes instead of collecting every chirp
+func (db *DB) GetChirpsPage(q ChirpQuery) (ChirpPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	ids := db.index.chirpIDs
+	if q.AuthorID != 0 {
+		ids = db.index.chirpsByAuthor[q.AuthorID]
+	}
+
+	return db.pageChirps(ids, q), nil
+}
+
+// pageLimit is the page size asked for by q
+func pageLimit(q ChirpQuery) int {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	return min(limit, MaxPageLimit)
+}
+
+// pageChirps walks ascending ids within the query bounds, must hold the lock
+func (db *DB) pageChirps(ids []int, q ChirpQuery) ChirpPage {
+	limit := pageLimit(q)
+	window := db.chirpWindow(ids, q)
+	filter := db.filterFor(q.ViewerID)
+	page := ChirpPage{Chirps: []Chirp{}}
+
+	for n := 0; n < len(window); n++ {
+		i := n
+		if q.Desc {
+			i = len(window) - 1 - n
+		}
+
+		chirp, ok := db.database.Chirps[window[i]]
+		if !ok || !filter.listed(chirp) {
+			continue
+		}
+		page.Chirps = append(page.Chirps, chirp)
+
+		// Fetch one past the limit, so the last page has no cursor even when
+		// hidden chirps follow it
+		if len(page.Chirps) > limit {
+			page.Chirps = page.Chirps[:limit]
+			page.Next = page.Chirps[limit-1].Id
+			break
+		}
+	}
+
+	return page
+}
+
+// chirpWindow narrows ascending ids to those within the query bounds,
+// ignoring the limit. Must hold the lock.
+func (db *DB) chirpWindow(ids []int, q ChirpQuery) []int {
+	// Lowest id allowed and one past the highest
+	low := q.SinceID + 1
+	high := len(ids)
+	if q.MaxID > 0 {
+		high, _ = slices.BinarySearch(ids, q.MaxID+1)
+	}
+	if q.After > 0 {
+		if q.Desc {
+			i, _ := slices.BinarySearch(ids, q.After)
+			high = min(high, i)
+		} else {
+			low = max(low, q.After+1)
+		}
+	}
+	start, _ := slices.BinarySearch(ids, low)
+
+	// Ids are handed out in creation order, so creation times ascend with
+	// them and time bounds are a binary search too
+	if !q.CreatedAfter.IsZero() {
+		start += sort.Search(max(high-start, 0), func(i int) bool {
+			return db.database.Chirps[ids[start+i]].CreatedAt.After(q.CreatedAfter)
+		})
+	}
+	if !q.CreatedBefore.IsZero() {
+		high = start + sort.Search(max(high-start, 0), func(i int) bool {
+			return !db.database.Chirps[ids[start+i]].CreatedAt.Before(q.CreatedBefore)
+		})
+	}
+
+	if start >= high {
+		return nil
+	}
+	return ids[start:high]
+}
+
+// mergeWindows merges several ascending id lists into one holding enough ids
+// for the page q asks for. Must hold the lock.
+func (db *DB) mergeWindows(lists [][]int, q ChirpQuery) []int {
+	// A page never needs more than limit+1 listed ids from any one list
+	n := pageLimit(q) + 1
+	filter := db.filterFor(q.ViewerID)
+
+	merged := []int{}
+	for _, ids := range lists {
+		merged = append(merged, db.firstListed(db.chirpWindow(ids, q), filter, n, q.Desc)...)
+	}
+	slices.Sort(merged)
+
+	return slices.Compact(merged)
+}
+
+// EncodeCursor turns a page position into an opaque token
+func EncodeCursor(after int) string {
+	return encodeToken("c", after)
+}
+
+// DecodeCursor reads a token made by EncodeCursor
+func DecodeCursor(cursor string) (int, error) {
+	return decodeToken("c", cursor)
+}
+
+// EncodeOffsetCursor turns a result offset, as search pages by, into an
+// opaque token. Offset and id cursors reject each other.
+func EncodeOffsetCursor(offset int) string {
+	return encodeToken("o", offset)
+}
+
+// DecodeOffsetCursor reads a token made by EncodeOffsetCursor
+func DecodeOffsetCursor(cursor string) (int, error) {
+	return decodeToken("o", cursor)
+}
+
+func encodeToken(kind string, n int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(kind + strconv.Itoa(n)))
+}
+
+func decodeToken(kind string, cursor string) (int, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrBadCursor
+	}
+
+	s, ok := strings.CutPrefix(string(dat), kind)
+	if !ok {
+		return 0, ErrBadCursor
+	}
+
+	after, err := strconv.Atoi(s)
+	if err != nil || after < 0 {
+		return 0, ErrBadCursor
+	}
+
+	return after, nil
+}
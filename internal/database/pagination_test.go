@@ -0,0 +1,111 @@
+package database
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func pageIDs(page ChirpPage) []int {
+	ids := []int{}
+	for _, chirp := range page.Chirps {
+		ids = append(ids, chirp.Id)
+	}
+	return ids
+}
+
+func TestGetChirpsPage(t *testing.T) {
+	db := InitCleanDB()
+	for i := 1; i <= 10; i++ {
+		_, err := db.CreateChirp(i%2+1, "chirp")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		q    ChirpQuery
+		ids  []int
+		next int
+	}{
+		{"first page", ChirpQuery{Limit: 3}, []int{1, 2, 3}, 3},
+		{"resume", ChirpQuery{Limit: 3, After: 3}, []int{4, 5, 6}, 6},
+		{"last page", ChirpQuery{Limit: 5, After: 6}, []int{7, 8, 9, 10}, 0},
+		{"desc", ChirpQuery{Limit: 3, Desc: true}, []int{10, 9, 8}, 8},
+		{"desc resume", ChirpQuery{Limit: 3, Desc: true, After: 8}, []int{7, 6, 5}, 5},
+		{"since and max", ChirpQuery{SinceID: 3, MaxID: 6}, []int{4, 5, 6}, 0},
+		{"desc since and max", ChirpQuery{Desc: true, SinceID: 3, MaxID: 6, Limit: 2}, []int{6, 5}, 5},
+		{"author", ChirpQuery{AuthorID: 1}, []int{2, 4, 6, 8, 10}, 0},
+		{"empty", ChirpQuery{SinceID: 10}, []int{}, 0},
+	}
+
+	for _, tt := range tests {
+		page, err := db.GetChirpsPage(tt.q)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if !slices.Equal(pageIDs(page), tt.ids) || page.Next != tt.next {
+			t.Errorf("%s: expected %v next %d, got %v next %d", tt.name, tt.ids, tt.next, pageIDs(page), page.Next)
+		}
+	}
+}
+
+func TestLastPageHasNoCursor(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+	db.CreateChirp(alice.Id, "one")
+	db.CreateChirp(alice.Id, "two")
+	db.CreateChirp(bob.Id, "hidden")
+	db.Block(alice.Id, bob.Id)
+
+	// The page is full but only hidden chirps follow it
+	page, _ := db.GetChirpsPage(ChirpQuery{Limit: 2, ViewerID: alice.Id})
+	if !slices.Equal(pageIDs(page), []int{1, 2}) || page.Next != 0 {
+		t.Errorf("expected [1 2] without a cursor, got %v next %d", pageIDs(page), page.Next)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	after, err := DecodeCursor(EncodeCursor(42))
+	if err != nil || after != 42 {
+		t.Errorf("expected 42, got %d, %v", after, err)
+	}
+
+	_, err = DecodeCursor("not a cursor")
+	if err != ErrBadCursor {
+		t.Errorf("expected ErrBadCursor, got %v", err)
+	}
+	// Offset and id cursors can't stand in for each other
+	if _, err := DecodeCursor(EncodeOffsetCursor(42)); err != ErrBadCursor {
+		t.Errorf("expected ErrBadCursor for an offset cursor, got %v", err)
+	}
+	if _, err := DecodeOffsetCursor(EncodeCursor(42)); err != ErrBadCursor {
+		t.Errorf("expected ErrBadCursor for an id cursor, got %v", err)
+	}
+}
+
+func TestGetChirpsPageByCreatedTime(t *testing.T) {
+	db := InitCleanDB()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		chirp, err := db.CreateChirp(1, "chirp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		chirp.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		db.database.Chirps[chirp.Id] = chirp
+	}
+
+	page, err := db.GetChirpsPage(ChirpQuery{
+		CreatedAfter:  base.Add(2 * time.Hour),
+		CreatedBefore: base.Add(5 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pageIDs(page), []int{3, 4}) {
+		t.Errorf("expected [3 4], got %v", pageIDs(page))
+	}
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"errors"
+	"sort"
+)
+
+const (
+	// Chirps a user can pin to their profile on the default plans
+	MaxPins    = 1
+	MaxRedPins = 3
+)
+
+var ErrPinLimit error = errors.New("pin limit reached, unpin a chirp first")
+
+// Pin puts one of userID's chirps at the top of their profile, pinning twice
+// is a no-op
+func (db *DB) Pin(userID int, chirpID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.database.Chirps[chirpID]
+	if !ok {
+		return ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return ErrChirpDeleted
+	}
+	if chirp.AuthorId != userID {
+		return ErrNotAuthorized
+	}
+	if db.hasRelation(RelationPin, userID, chirpID) {
+		return nil
+	}
+	if len(db.index.relationsFrom.get(RelationPin, userID)) >= db.entitlements(userID).MaxPins {
+		return ErrPinLimit
+	}
+
+	_, err := db.addRelation(RelationPin, userID, chirpID)
+	return err
+}
+
+// Unpin takes a chirp off userID's profile, a no-op if it wasn't pinned
+func (db *DB) Unpin(userID int, chirpID int) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.database.Chirps[chirpID]; !ok {
+		return ErrChirpNotFound
+	}
+
+	_, err := db.removeRelation(RelationPin, userID, chirpID)
+	return err
+}
+
+// GetPinned returns the chirps authorID pinned that viewerID can see, most
+// recently pinned first. Pins past the limit of the author's current plan,
+// kept from a lapsed Chirpy Red subscription, are left out.
+func (db *DB) GetPinned(authorID int, viewerID int) ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	pins := []Relation{}
+	for _, id := range db.index.relationsFrom.get(RelationPin, authorID) {
+		pins = append(pins, db.database.Relations[relationKey(RelationPin, authorID, id)])
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if !pins[i].CreatedAt.Equal(pins[j].CreatedAt) {
+			return pins[i].CreatedAt.After(pins[j].CreatedAt)
+		}
+		return pins[i].To > pins[j].To
+	})
+	pins = pins[:min(len(pins), db.entitlements(authorID).MaxPins)]
+
+	filter := db.filterFor(viewerID)
+	chirps := []Chirp{}
+	for _, pin := range pins {
+		chirp, ok := db.database.Chirps[pin.To]
+		if ok && chirp.DeletedAt == nil && filter.listed(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+
+	return chirps, nil
+}
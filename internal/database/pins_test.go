@@ -0,0 +1,60 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPinLimits(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+
+	chirps := []Chirp{}
+	for _, body := range []string{"one", "two", "three", "four"} {
+		chirp, _ := db.CreateChirp(alice.Id, body)
+		chirps = append(chirps, chirp)
+	}
+
+	if err := db.Pin(bob.Id, chirps[0].Id); !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("expected ErrNotAuthorized, got %v", err)
+	}
+	if err := db.Pin(alice.Id, chirps[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Pin(alice.Id, chirps[1].Id); !errors.Is(err, ErrPinLimit) {
+		t.Errorf("expected ErrPinLimit, got %v", err)
+	}
+
+	db.UpgradeUser(alice.Id)
+	for _, chirp := range chirps[1:3] {
+		if err := db.Pin(alice.Id, chirp.Id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Pin(alice.Id, chirps[3].Id); !errors.Is(err, ErrPinLimit) {
+		t.Errorf("expected ErrPinLimit, got %v", err)
+	}
+
+	pinned, _ := db.GetPinned(alice.Id, 0)
+	if len(pinned) != 3 || pinned[0].Id != chirps[2].Id {
+		t.Errorf("expected newest pin first, got %v", pinned)
+	}
+}
+
+func TestDeleteUnpins(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	chirp, _ := db.CreateChirp(alice.Id, "pinned")
+
+	db.Pin(alice.Id, chirp.Id)
+	db.DeleteChirp(chirp.Id)
+	db.RestoreChirp(chirp.Id, alice.Id)
+
+	if pinned, _ := db.GetPinned(alice.Id, alice.Id); len(pinned) != 0 {
+		t.Errorf("expected pin to be removed, got %v", pinned)
+	}
+	if db.hasRelation(RelationPin, alice.Id, chirp.Id) {
+		t.Error("expected pin relation to be removed")
+	}
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// How many options a poll has
+	MinPollOptions = 2
+	MaxPollOptions = 4
+
+	// Longest poll option, in runes
+	MaxPollOptionLength = 25
+
+	// How long a poll can stay open
+	MinPollDuration = 5 * time.Minute
+	MaxPollDuration = 7 * 24 * time.Hour
+)
+
+var ErrBadPoll error = errors.New("poll needs 2 to 4 distinct options of at most 25 characters")
+var ErrBadPollExpiry error = errors.New("poll must close between 5 minutes and 7 days from now")
+var ErrNoPoll error = errors.New("chirp has no poll")
+var ErrPollClosed error = errors.New("poll is closed")
+var ErrAlreadyVoted error = errors.New("already voted in this poll")
+var ErrBadPollOption error = errors.New("poll has no such option")
+
+// Poll is a question attached to a chirp
+type Poll struct {
+	Options   []string  `json:"options"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PollView is a poll as seen by one viewer. Votes per option are hidden
+// until the viewer has voted or the poll closes.
+type PollView struct {
+	Options   []PollOption `json:"options"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Closed    bool         `json:"closed"`
+	VoteCount int          `json:"vote_count"`
+
+	// Index of the option the viewer voted for, nil when they haven't
+	MyVote *int `json:"my_vote,omitempty"`
+}
+
+type PollOption struct {
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+}
+
+// newPoll validates the poll of a new chirp, nil when there is none
+func newPoll(p *Poll) (*Poll, error) {
+	if p == nil {
+		return nil, nil
+	}
+	if len(p.Options) < MinPollOptions || len(p.Options) > MaxPollOptions {
+		return nil, ErrBadPoll
+	}
+
+	options := make([]string, 0, len(p.Options))
+	seen := map[string]bool{}
+	for _, option := range p.Options {
+		option = strings.TrimSpace(option)
+		key := strings.ToLower(option)
+		if option == "" || utf8.RuneCountInString(option) > MaxPollOptionLength || seen[key] {
+			return nil, ErrBadPoll
+		}
+		seen[key] = true
+		options = append(options, option)
+	}
+
+	open := time.Until(p.ExpiresAt)
+	if open < MinPollDuration || open > MaxPollDuration {
+		return nil, ErrBadPollExpiry
+	}
+
+	return &Poll{Options: options, ExpiresAt: p.ExpiresAt.UTC()}, nil
+}
+
+// Closed reports whether the poll has stopped taking votes
+func (p *Poll) Closed() bool {
+	return !time.Now().Before(p.ExpiresAt)
+}
+
+// Vote records userID's choice of option, an index into the poll options.
+// Each user votes once and can't change their vote.
+func (db *DB) Vote(userID int, chirpID int, option int) (ChirpView, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.database.Chirps[chirpID]
+	if !ok || !db.filterFor(userID).shown(chirp) {
+		return ChirpView{}, ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return ChirpView{}, ErrChirpDeleted
+	}
+	if chirp.Poll == nil {
+		return ChirpView{}, ErrNoPoll
+	}
+	if chirp.Poll.Closed() {
+		return ChirpView{}, ErrPollClosed
+	}
+	if option < 0 || option >= len(chirp.Poll.Options) {
+		return ChirpView{}, ErrBadPollOption
+	}
+
+	added, err := db.addRelationValue(RelationVote, userID, chirpID, option)
+	if err != nil {
+		return ChirpView{}, err
+	}
+	if !added {
+		return ChirpView{}, ErrAlreadyVoted
+	}
+
+	return db.viewChirp(chirp, userID), nil
+}
+
+// viewPoll shows the poll of a chirp to viewerID, must hold the lock
+func (db *DB) viewPoll(chirp Chirp, viewerID int) *PollView {
+	view := &PollView{
+		Options:   make([]PollOption, 0, len(chirp.Poll.Options)),
+		ExpiresAt: chirp.Poll.ExpiresAt,
+		Closed:    chirp.Poll.Closed(),
+		VoteCount: len(db.index.relationsTo.get(RelationVote, chirp.Id)),
+	}
+
+	if vote, ok := db.database.Relations[relationKey(RelationVote, viewerID, chirp.Id)]; ok && viewerID != 0 {
+		view.MyVote = &vote.Value
+	}
+
+	tally := db.index.pollVotes[chirp.Id]
+	for i, text := range chirp.Poll.Options {
+		option := PollOption{Text: text}
+		if view.Closed || view.MyVote != nil {
+			votes := 0
+			if i < len(tally) {
+				votes = tally[i]
+			}
+			option.Votes = &votes
+		}
+		view.Options = append(view.Options, option)
+	}
+
+	return view
+}
+
+// countVote adds delta to the tally of the option a vote is for
+func (ix *indexes) countVote(rel Relation, delta int) {
+	if rel.Kind != RelationVote || rel.Value < 0 {
+		return
+	}
+
+	tally := ix.pollVotes[rel.To]
+	for len(tally) <= rel.Value {
+		tally = append(tally, 0)
+	}
+	tally[rel.Value] += delta
+	ix.pollVotes[rel.To] = tally
+
+	if delta < 0 && slices.IndexFunc(tally, func(n int) bool { return n != 0 }) < 0 {
+		delete(ix.pollVotes, rel.To)
+	}
+}
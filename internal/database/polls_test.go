@@ -0,0 +1,65 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPollVotes(t *testing.T) {
+	db := InitCleanDB()
+
+	_, err := db.CreateChirpWith(1, ChirpParams{Poll: &Poll{Options: []string{"only"}, ExpiresAt: time.Now().Add(time.Hour)}})
+	if !errors.Is(err, ErrBadPoll) {
+		t.Errorf("expected ErrBadPoll, got %v", err)
+	}
+	_, err = db.CreateChirpWith(1, ChirpParams{Poll: &Poll{Options: []string{"a", "b"}, ExpiresAt: time.Now()}})
+	if !errors.Is(err, ErrBadPollExpiry) {
+		t.Errorf("expected ErrBadPollExpiry, got %v", err)
+	}
+
+	chirp, err := db.CreateChirpWith(1, ChirpParams{Body: "tabs?", Poll: &Poll{
+		Options:   []string{"tabs", "spaces"},
+		ExpiresAt: time.Now().Add(time.Hour),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tallies stay hidden until the viewer votes
+	view := db.ViewChirp(chirp, 2)
+	if view.Poll == nil || view.Poll.Options[0].Votes != nil || view.Poll.MyVote != nil {
+		t.Errorf("expected hidden tallies, got %+v", view.Poll)
+	}
+
+	db.Vote(3, chirp.Id, 1)
+	view, err = db.Vote(2, chirp.Id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *view.Poll.MyVote != 1 || *view.Poll.Options[0].Votes != 0 || *view.Poll.Options[1].Votes != 2 || view.Poll.VoteCount != 2 {
+		t.Errorf("unexpected results %+v", view.Poll)
+	}
+
+	_, err = db.Vote(2, chirp.Id, 0)
+	if !errors.Is(err, ErrAlreadyVoted) {
+		t.Errorf("expected ErrAlreadyVoted, got %v", err)
+	}
+	_, err = db.Vote(4, chirp.Id, 2)
+	if !errors.Is(err, ErrBadPollOption) {
+		t.Errorf("expected ErrBadPollOption, got %v", err)
+	}
+
+	// Everyone sees the results once the poll closes
+	db.mux.Lock()
+	chirp.Poll.ExpiresAt = time.Now().Add(-time.Second)
+	db.mux.Unlock()
+	view = db.ViewChirp(chirp, 0)
+	if !view.Poll.Closed || view.Poll.Options[1].Votes == nil || *view.Poll.Options[1].Votes != 2 {
+		t.Errorf("expected public results, got %+v", view.Poll)
+	}
+	_, err = db.Vote(4, chirp.Id, 0)
+	if !errors.Is(err, ErrPollClosed) {
+		t.Errorf("expected ErrPollClosed, got %v", err)
+	}
+}
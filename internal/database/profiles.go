@@ -0,0 +1,192 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Profile field limits, in runes
+const (
+	MinHandleLength      = 3
+	MaxHandleLength      = 30
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 160
+)
+
+var ErrHandleTaken error = errors.New("handle is taken")
+var ErrBadHandle error = fmt.Errorf("handle must be %d to %d letters, digits or underscores and not only digits", MinHandleLength, MaxHandleLength)
+var ErrDisplayNameTooLong error = errors.New("display name is too long")
+var ErrBioTooLong error = errors.New("bio is too long")
+
+// UserParams are the details of a new account, profile fields are optional
+type UserParams struct {
+	Email    string
+	Password string
+
+	Handle      string
+	DisplayName string
+	Bio         string
+}
+
+// ProfileUpdate changes the profile fields that are not nil
+type ProfileUpdate struct {
+	Handle      *string `json:"handle"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+}
+
+// PublicUser is what other users may see of an account, never the email
+type PublicUser struct {
+	Id          int    `json:"id"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+	Plan        string `json:"plan"`
+}
+
+func (u User) Public() PublicUser {
+	return PublicUser{
+		Id:          u.Id,
+		Handle:      u.Handle,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		IsChirpyRed: u.IsChirpyRed,
+		Plan:        u.Plan,
+	}
+}
+
+// handleKey is how handles are compared, ignoring case and a leading @
+func handleKey(handle string) string {
+	return strings.ToLower(strings.TrimPrefix(handle, "@"))
+}
+
+// validHandle checks a handle can be mentioned in a chirp and told apart
+// from a user id
+func validHandle(handle string) bool {
+	n := utf8.RuneCountInString(handle)
+	if n < MinHandleLength || n > MaxHandleLength {
+		return false
+	}
+	if strings.IndexFunc(handle, func(r rune) bool { return !isWordRune(r) }) >= 0 {
+		return false
+	}
+	_, err := strconv.Atoi(handle)
+	return err != nil
+}
+
+// validateProfile checks the profile fields, an empty handle is allowed
+func validateProfile(handle string, displayName string, bio string) error {
+	if handle != "" && !validHandle(handle) {
+		return ErrBadHandle
+	}
+	if utf8.RuneCountInString(displayName) > MaxDisplayNameLength {
+		return ErrDisplayNameTooLong
+	}
+	if utf8.RuneCountInString(bio) > MaxBioLength {
+		return ErrBioTooLong
+	}
+	return nil
+}
+
+// handleTaken reports whether another user than userID has handle, must
+// hold the lock
+func (db *DB) handleTaken(handle string, userID int) bool {
+	id, ok := db.index.usersByHandle[handleKey(handle)]
+	return ok && id != userID
+}
+
+// defaultHandle picks a free handle for a user who didn't choose one, must
+// hold the lock
+func (db *DB) defaultHandle(userID int) string {
+	handle := fmt.Sprintf("user%d", userID)
+	for n := 2; db.handleTaken(handle, userID); n++ {
+		handle = fmt.Sprintf("user%d_%d", userID, n)
+	}
+	return handle
+}
+
+// UpdateProfile changes the handle, display name or bio of a user
+func (db *DB) UpdateProfile(userID int, update ProfileUpdate) (User, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.database.Users[userID]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	old := user
+
+	if update.Handle != nil {
+		if !validHandle(*update.Handle) {
+			return User{}, ErrBadHandle
+		}
+		if db.handleTaken(*update.Handle, userID) {
+			return User{}, ErrHandleTaken
+		}
+		user.Handle = *update.Handle
+	}
+	if update.DisplayName != nil {
+		user.DisplayName = *update.DisplayName
+	}
+	if update.Bio != nil {
+		user.Bio = *update.Bio
+	}
+
+	err := validateProfile(user.Handle, user.DisplayName, user.Bio)
+	if err != nil {
+		return User{}, err
+	}
+
+	db.database.Users[userID] = user
+	db.index.setUser(old, user)
+
+	return user, db.store.SaveUser(user)
+}
+
+// SetAvatar points a user's avatar at url, returning the previous one
+func (db *DB) SetAvatar(userID int, url string) (string, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.database.Users[userID]
+	if !ok {
+		return "", ErrUserNotFound
+	}
+
+	old := user.AvatarURL
+	user.AvatarURL = url
+	db.database.Users[userID] = user
+
+	return old, db.store.SaveUser(user)
+}
+
+// ResolveUser finds a user by handle, with or without the @, or by id
+func (db *DB) ResolveUser(ref string) (User, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.resolveUser(ref)
+}
+
+// resolveUser must hold the lock
+func (db *DB) resolveUser(ref string) (User, error) {
+	if id, ok := db.index.usersByHandle[handleKey(ref)]; ok {
+		return db.database.Users[id], nil
+	}
+
+	// Handles are never only digits, so this is an id
+	id, err := strconv.Atoi(ref)
+	if err == nil {
+		if user, ok := db.database.Users[id]; ok {
+			return user, nil
+		}
+	}
+
+	return User{}, ErrUserNotFound
+}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	db := InitCleanDB()
+
+	alice, err := db.CreateUserWith(UserParams{Email: "a@b.c", Handle: "Alice", Bio: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := db.CreateUser("d@e.f", "password")
+	if other.Handle != "user2" {
+		t.Errorf("expected default handle user2, got %q", other.Handle)
+	}
+
+	_, err = db.CreateUserWith(UserParams{Email: "b@c.d", Handle: "alice"})
+	if !errors.Is(err, ErrHandleTaken) {
+		t.Errorf("expected ErrHandleTaken, got %v", err)
+	}
+	for _, handle := range []string{"al", "12345", "has space", "@at"} {
+		_, err = db.CreateUserWith(UserParams{Handle: handle})
+		if !errors.Is(err, ErrBadHandle) {
+			t.Errorf("%q: expected ErrBadHandle, got %v", handle, err)
+		}
+	}
+
+	for _, ref := range []string{"alice", "@ALICE", "1"} {
+		user, err := db.ResolveUser(ref)
+		if err != nil || user.Id != alice.Id {
+			t.Errorf("%q: expected alice, got %v, %v", ref, user, err)
+		}
+	}
+
+	handle := "ally"
+	name := "Ally"
+	_, err = db.UpdateProfile(alice.Id, ProfileUpdate{Handle: &handle, DisplayName: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ResolveUser("alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("expected old handle released, got %v", err)
+	}
+
+	// Changing the email keeps the profile
+	updated, _ := db.UpdateUser(alice.Id, "new@b.c", "password")
+	if updated.Handle != "ally" || updated.Bio != "hi" {
+		t.Errorf("expected profile kept, got %+v", updated)
+	}
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuoteChirps(t *testing.T) {
+	db := InitCleanDB()
+
+	original, _ := db.CreateChirp(1, "original")
+	quote, err := db.CreateChirpWith(2, ChirpParams{Body: "so true", QuoteOf: original.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	view := db.ViewChirp(quote, 0)
+	if view.Quoted == nil || view.Quoted.Body != "original" || view.Quoted.AuthorId != 1 || view.Quoted.Unavailable {
+		t.Errorf("expected embedded quote, got %+v", view.Quoted)
+	}
+
+	// Blocked quoters only see a placeholder
+	db.mux.Lock()
+	db.addRelation(RelationBlock, 1, 2)
+	db.mux.Unlock()
+	view = db.ViewChirp(quote, 0)
+	if !view.Quoted.Unavailable || view.Quoted.Deleted || view.Quoted.Body != "" {
+		t.Errorf("expected blocked placeholder, got %+v", view.Quoted)
+	}
+
+	db.DeleteChirp(original.Id)
+	view = db.ViewChirp(quote, 0)
+	if !view.Quoted.Deleted || view.Quoted.Body != "" {
+		t.Errorf("expected deleted placeholder, got %+v", view.Quoted)
+	}
+
+	_, err = db.CreateChirpWith(2, ChirpParams{Body: "gone", QuoteOf: original.Id})
+	if !errors.Is(err, ErrQuoteTargetNotFound) {
+		t.Errorf("expected ErrQuoteTargetNotFound, got %v", err)
+	}
+}
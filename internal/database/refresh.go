@@ -1,20 +1,27 @@
 package database
 
-func (db *DB) AddRefreshToken(refreshToken string) {
+func (db *DB) AddRefreshToken(refreshToken string) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
 	db.database.RefreshTokens[refreshToken] = true
+
+	return db.store.SaveRefreshToken(refreshToken)
 }
 
-func (db *DB) RevokeRefreshToken(refreshToken string) {
+func (db *DB) RevokeRefreshToken(refreshToken string) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
 	delete(db.database.RefreshTokens, refreshToken)
+
+	return db.store.RemoveRefreshToken(refreshToken)
 }
 
 func (db *DB) IsValidRefreshToken(refreshToken string) bool {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
 	_, ok := db.database.RefreshTokens[refreshToken]
 	return ok
 }
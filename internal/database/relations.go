@@ -0,0 +1,143 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Relation kinds
+const (
+	// User From follows user To
+	RelationFollow = "follow"
+
+	// User From likes or rechirped chirp To
+	RelationLike    = "like"
+	RelationRechirp = "rechirp"
+
+	// User From blocks or mutes user To
+	RelationBlock = "block"
+	RelationMute  = "mute"
+
+	// User From voted for option Value in the poll of chirp To
+	RelationVote = "vote"
+
+	// User From bookmarked or pinned chirp To
+	RelationBookmark = "bookmark"
+	RelationPin      = "pin"
+
+	// List From has user To as a member
+	RelationListMember = "list_member"
+)
+
+// Relation is a directed edge of some kind, such as one user following
+// another
+type Relation struct {
+	Kind      string    `json:"kind"`
+	From      int       `json:"from"`
+	To        int       `json:"to"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Payload of kinds that need one, such as the option voted for
+	Value int `json:"value,omitempty"`
+}
+
+func (rel Relation) key() string {
+	return relationKey(rel.Kind, rel.From, rel.To)
+}
+
+func relationKey(kind string, from int, to int) string {
+	return fmt.Sprintf("%s:%d:%d", kind, from, to)
+}
+
+// hasRelation reports whether the edge exists, must hold the lock
+func (db *DB) hasRelation(kind string, from int, to int) bool {
+	_, ok := db.database.Relations[relationKey(kind, from, to)]
+	return ok
+}
+
+// addRelation saves an edge, returning false if it already existed. Must
+// hold the write lock.
+func (db *DB) addRelation(kind string, from int, to int) (bool, error) {
+	return db.addRelationValue(kind, from, to, 0)
+}
+
+// addRelationValue saves an edge carrying a value, returning false if it
+// already existed. Must hold the write lock.
+func (db *DB) addRelationValue(kind string, from int, to int, value int) (bool, error) {
+	if db.hasRelation(kind, from, to) {
+		return false, nil
+	}
+
+	rel := Relation{Kind: kind, From: from, To: to, CreatedAt: time.Now().UTC(), Value: value}
+	db.database.Relations[rel.key()] = rel
+	db.index.addRelation(rel)
+
+	return true, db.store.SaveRelation(rel)
+}
+
+// removeRelation deletes an edge, returning false if there was none. Must
+// hold the write lock.
+func (db *DB) removeRelation(kind string, from int, to int) (bool, error) {
+	rel, ok := db.database.Relations[relationKey(kind, from, to)]
+	if !ok {
+		return false, nil
+	}
+
+	delete(db.database.Relations, rel.key())
+	db.index.removeRelation(rel)
+
+	return true, db.store.RemoveRelation(rel)
+}
+
+// removeRelationsTo deletes every edge of kind pointing at to, must hold the
+// write lock
+func (db *DB) removeRelationsTo(kind string, to int) error {
+	from := slices.Clone(db.index.relationsTo.get(kind, to))
+	for _, id := range from {
+		_, err := db.removeRelation(kind, id, to)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relationIndex maps an id to the ids on the other end of its edges
+type relationIndex map[string]map[int][]int
+
+func (ri relationIndex) get(kind string, id int) []int {
+	return ri[kind][id]
+}
+
+func (ri relationIndex) add(kind string, id int, other int) {
+	m, ok := ri[kind]
+	if !ok {
+		m = make(map[int][]int)
+		ri[kind] = m
+	}
+	m[id] = insertSorted(m[id], other)
+}
+
+func (ri relationIndex) remove(kind string, id int, other int) {
+	removeKeyed(ri[kind], id, other)
+}
+
+// append adds without keeping order, for building before sort
+func (ri relationIndex) append(kind string, id int, other int) {
+	m, ok := ri[kind]
+	if !ok {
+		m = make(map[int][]int)
+		ri[kind] = m
+	}
+	m[id] = append(m[id], other)
+}
+
+func (ri relationIndex) sort() {
+	for _, m := range ri {
+		for _, ids := range m {
+			sort.Ints(ids)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrEditWindowClosed error = errors.New("edit window has closed")
+var ErrRevisionNotFound error = errors.New("revision not found")
+
+// Revision is one immutable version of a chirp body
+type Revision struct {
+	ChirpId   int       `json:"chirp_id"`
+	Number    int       `json:"number"`
+	Body      string    `json:"body"`
+	EditorId  int       `json:"editor_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EditChirp replaces the body of a chirp, keeping the previous body as a
+// revision. Only the author may edit, within the edit window.
+func (db *DB) EditChirp(chirpID int, editorID int, body string) (Chirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.database.Chirps[chirpID]
+	if !ok {
+		return Chirp{}, ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return Chirp{}, ErrChirpDeleted
+	}
+
+	// Only allow author to edit chirps
+	if chirp.AuthorId != editorID {
+		return Chirp{}, ErrNotAuthorized
+	}
+
+	return db.editChirp(chirp, editorID, body)
+}
+
+// editChirp records the revision and saves the chirp, must hold the lock
+func (db *DB) editChirp(chirp Chirp, editorID int, body string) (Chirp, error) {
+	plan := db.entitlements(chirp.AuthorId)
+	now := time.Now().UTC()
+	if plan.EditWindow > 0 && now.Sub(chirp.CreatedAt) > plan.EditWindow {
+		return Chirp{}, ErrEditWindowClosed
+	}
+	if len(body) > plan.MaxChirpLength {
+		return Chirp{}, ErrChirpTooLong
+	}
+	err := db.checkMentions(chirp.AuthorId, body)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	revisions := db.database.Revisions[chirp.Id]
+
+	// The original body becomes the first revision on the first edit
+	if len(revisions) == 0 {
+		original := Revision{
+			ChirpId:   chirp.Id,
+			Number:    1,
+			Body:      chirp.Body,
+			EditorId:  chirp.AuthorId,
+			CreatedAt: chirp.CreatedAt,
+		}
+		err := db.saveRevision(original)
+		if err != nil {
+			return Chirp{}, err
+		}
+		revisions = db.database.Revisions[chirp.Id]
+	}
+
+	rev := Revision{
+		ChirpId:   chirp.Id,
+		Number:    len(revisions) + 1,
+		Body:      body,
+		EditorId:  editorID,
+		CreatedAt: now,
+	}
+	err = db.saveRevision(rev)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	old := chirp
+	chirp.Body = body
+	chirp.Mentions = db.mentionedUsers(body)
+	chirp.UpdatedAt = now
+	chirp.Edited = true
+	db.database.Chirps[chirp.Id] = chirp
+	db.index.editChirp(old, chirp)
+
+	return chirp, db.store.SaveChirp(chirp)
+}
+
+func (db *DB) saveRevision(rev Revision) error {
+	db.database.Revisions[rev.ChirpId] = append(db.database.Revisions[rev.ChirpId], rev)
+	return db.store.SaveRevision(rev)
+}
+
+// GetRevisions lists every version of a chirp, oldest first. A chirp that
+// was never edited has its current body as the only revision.
+func (db *DB) GetRevisions(chirpID int) ([]Revision, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	chirp, ok := db.database.Chirps[chirpID]
+	if !ok {
+		return nil, ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return nil, ErrChirpDeleted
+	}
+
+	return db.revisions(chirp), nil
+}
+
+// revisions lists every version of a chirp, must hold the lock
+func (db *DB) revisions(chirp Chirp) []Revision {
+	revisions := db.database.Revisions[chirp.Id]
+	if len(revisions) == 0 {
+		return []Revision{{
+			ChirpId:   chirp.Id,
+			Number:    1,
+			Body:      chirp.Body,
+			EditorId:  chirp.AuthorId,
+			CreatedAt: chirp.CreatedAt,
+		}}
+	}
+
+	return append([]Revision{}, revisions...)
+}
+
+// RestoreRevision makes an old revision the current body again, recorded as
+// a new revision so the history stays intact. Restoring the only revision
+// of a chirp never edited leaves it as it is.
+func (db *DB) RestoreRevision(chirpID int, number int, editorID int) (Chirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.database.Chirps[chirpID]
+	if !ok {
+		return Chirp{}, ErrChirpNotFound
+	}
+	if chirp.DeletedAt != nil {
+		return Chirp{}, ErrChirpDeleted
+	}
+
+	if chirp.AuthorId != editorID {
+		return Chirp{}, ErrNotAuthorized
+	}
+
+	revisions := db.revisions(chirp)
+	if number < 1 || number > len(revisions) {
+		return Chirp{}, ErrRevisionNotFound
+	}
+	if len(db.database.Revisions[chirpID]) == 0 {
+		return chirp, nil
+	}
+
+	return db.editChirp(chirp, editorID, revisions[number-1].Body)
+}
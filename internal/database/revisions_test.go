@@ -0,0 +1,73 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEditKeepsRevisions(t *testing.T) {
+	db := InitCleanDB()
+
+	chirp, err := db.CreateChirp(1, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The revision listed for a chirp never edited can be restored
+	revisions, _ := db.GetRevisions(chirp.Id)
+	restored, err := db.RestoreRevision(chirp.Id, revisions[0].Number, 1)
+	if err != nil || restored.Body != "first" || restored.Edited {
+		t.Errorf("expected restoring revision 1 to leave the chirp as is, got %+v %v", restored, err)
+	}
+
+	_, err = db.EditChirp(chirp.Id, 2, "not mine")
+	if !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("expected ErrNotAuthorized, got %v", err)
+	}
+
+	edited, err := db.EditChirp(chirp.Id, 1, "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !edited.Edited || edited.Body != "second" {
+		t.Errorf("expected edited chirp, got %+v", edited)
+	}
+
+	restored, err = db.RestoreRevision(chirp.Id, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Body != "first" {
+		t.Errorf("expected restored body, got %q", restored.Body)
+	}
+
+	revisions, err = db.GetRevisions(chirp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := []string{}
+	for _, rev := range revisions {
+		bodies = append(bodies, rev.Body)
+	}
+	if len(bodies) != 3 || bodies[0] != "first" || bodies[1] != "second" || bodies[2] != "first" {
+		t.Errorf("expected history first, second, first, got %v", bodies)
+	}
+}
+
+func TestEditWindow(t *testing.T) {
+	db := InitCleanDB()
+	db.SetEditWindow(time.Minute)
+
+	chirp, err := db.CreateChirp(1, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chirp.CreatedAt = chirp.CreatedAt.Add(-time.Hour)
+	db.database.Chirps[chirp.Id] = chirp
+
+	_, err = db.EditChirp(chirp.Id, 1, "too late")
+	if !errors.Is(err, ErrEditWindowClosed) {
+		t.Errorf("expected ErrEditWindowClosed, got %v", err)
+	}
+}
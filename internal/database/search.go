@@ -0,0 +1,293 @@
+package database
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+)
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var ErrEmptySearch error = errors.New("search query is empty")
+var ErrBadSearchFilter error = errors.New("from: needs a handle or user id")
+
+// searchIndex is a positional inverted index over live chirp bodies
+type searchIndex struct {
+	// Term -> chirp id -> positions of the term in the body
+	postings map[string]map[int][]int
+
+	// Chirp id -> number of terms in the body
+	lengths map[int]int
+	total   int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[int][]int),
+		lengths:  make(map[int]int),
+	}
+}
+
+func (si *searchIndex) add(chirp Chirp) {
+	terms := tokenize(chirp.Body)
+	for pos, term := range terms {
+		docs, ok := si.postings[term]
+		if !ok {
+			docs = make(map[int][]int)
+			si.postings[term] = docs
+		}
+		docs[chirp.Id] = append(docs[chirp.Id], pos)
+	}
+	si.lengths[chirp.Id] = len(terms)
+	si.total += len(terms)
+}
+
+func (si *searchIndex) remove(chirp Chirp) {
+	length, ok := si.lengths[chirp.Id]
+	if !ok {
+		return
+	}
+
+	for _, term := range tokenize(chirp.Body) {
+		docs := si.postings[term]
+		delete(docs, chirp.Id)
+		if len(docs) == 0 {
+			delete(si.postings, term)
+		}
+	}
+	delete(si.lengths, chirp.Id)
+	si.total -= length
+}
+
+// tokenize splits text into case folded words
+func tokenize(text string) []string {
+	terms := []string{}
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			terms = append(terms, foldCase(text[start:i]))
+			start = -1
+		}
+	}
+	if start >= 0 {
+		terms = append(terms, foldCase(text[start:]))
+	}
+
+	return terms
+}
+
+// foldCase maps the case variants of a word to one form. Going through upper
+// case first makes final sigma match sigma, and ß folds to ss as it does in
+// full case folding.
+func foldCase(s string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.ToUpper(s)), "ß", "ss")
+}
+
+// searchQuery is a parsed search: every term and phrase must match
+type searchQuery struct {
+	terms   []string
+	phrases [][]string
+
+	// Handle or id given to from:
+	author string
+}
+
+// parseSearch reads words, "quoted phrases" and from:<handle or id>. A word that
+// tokenizes into several terms, such as don't, is matched as a phrase.
+func parseSearch(s string) (searchQuery, error) {
+	sq := searchQuery{}
+
+	addWords := func(text string) {
+		terms := tokenize(text)
+		switch {
+		case len(terms) == 1:
+			sq.terms = append(sq.terms, terms[0])
+		case len(terms) > 1:
+			sq.phrases = append(sq.phrases, terms)
+		}
+	}
+
+	for s != "" {
+		s = strings.TrimLeft(s, " \t\n")
+		if s == "" {
+			break
+		}
+
+		if s[0] == '"' {
+			phrase, rest, _ := strings.Cut(s[1:], `"`)
+			s = rest
+			addWords(phrase)
+			continue
+		}
+
+		word := s
+		s = ""
+		if i := strings.IndexAny(word, " \t\n"); i >= 0 {
+			word, s = word[:i], word[i:]
+		}
+
+		if value, ok := strings.CutPrefix(word, "from:"); ok {
+			if strings.TrimPrefix(value, "@") == "" {
+				return sq, ErrBadSearchFilter
+			}
+			sq.author = value
+			continue
+		}
+		addWords(word)
+	}
+
+	if len(sq.terms) == 0 && len(sq.phrases) == 0 && sq.author == "" {
+		return sq, ErrEmptySearch
+	}
+
+	return sq, nil
+}
+
+// SearchChirps ranks the chirps matching query by relevance, newest first
+// when equally relevant. Pages are by offset, q.After being the number of
+// results already seen and ChirpPage.Next the offset of the following page.
+func (db *DB) SearchChirps(query string, q ChirpQuery) (ChirpPage, error) {
+	sq, err := parseSearch(query)
+	if err != nil {
+		return ChirpPage{}, err
+	}
+
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	si := db.index.search
+	filter := db.filterFor(q.ViewerID)
+	page := ChirpPage{Chirps: []Chirp{}}
+
+	authorID := 0
+	if sq.author != "" {
+		author, err := db.resolveUser(sq.author)
+		if err != nil {
+			// Nobody by that name has chirped
+			return page, nil
+		}
+		authorID = author.Id
+	}
+
+	// Every term, including those of phrases, must appear
+	terms := slices.Clone(sq.terms)
+	for _, phrase := range sq.phrases {
+		terms = append(terms, phrase...)
+	}
+	slices.Sort(terms)
+	terms = slices.Compact(terms)
+
+	var candidates []int
+	if len(terms) == 0 {
+		candidates = slices.Clone(db.listedIDs(db.index.chirpsByAuthor[authorID], filter))
+	} else {
+		// Walk the rarest term and check the others against it
+		slices.SortFunc(terms, func(a, b string) int {
+			return len(si.postings[a]) - len(si.postings[b])
+		})
+		for id := range si.postings[terms[0]] {
+			chirp := db.database.Chirps[id]
+			if authorID != 0 && chirp.AuthorId != authorID || !filter.listed(chirp) {
+				continue
+			}
+			if si.matches(id, terms[1:], sq.phrases) {
+				candidates = append(candidates, id)
+			}
+		}
+	}
+
+	scores := make(map[int]float64, len(candidates))
+	for _, id := range candidates {
+		scores[id] = si.score(id, terms)
+	}
+	slices.SortFunc(candidates, func(a, b int) int {
+		if scores[a] != scores[b] {
+			if scores[a] > scores[b] {
+				return -1
+			}
+			return 1
+		}
+		return b - a
+	})
+
+	offset := min(q.After, len(candidates))
+	end := min(offset+pageLimit(q), len(candidates))
+	for _, id := range candidates[offset:end] {
+		page.Chirps = append(page.Chirps, db.database.Chirps[id])
+	}
+	if end < len(candidates) {
+		page.Next = end
+	}
+
+	return page, nil
+}
+
+// matches checks that a chirp has every term and phrase
+func (si *searchIndex) matches(id int, terms []string, phrases [][]string) bool {
+	for _, term := range terms {
+		if _, ok := si.postings[term][id]; !ok {
+			return false
+		}
+	}
+
+	for _, phrase := range phrases {
+		if !si.hasPhrase(id, phrase) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasPhrase checks that the terms appear next to each other, in order
+func (si *searchIndex) hasPhrase(id int, phrase []string) bool {
+	for _, start := range si.postings[phrase[0]][id] {
+		found := true
+		for i, term := range phrase[1:] {
+			if _, ok := slices.BinarySearch(si.postings[term][id], start+i+1); !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// score is the BM25 relevance of a chirp to the terms
+func (si *searchIndex) score(id int, terms []string) float64 {
+	n := float64(len(si.lengths))
+	if n == 0 || si.total == 0 {
+		return 0
+	}
+	avgLength := float64(si.total) / n
+	length := float64(si.lengths[id])
+
+	score := 0.0
+	for _, term := range terms {
+		docs := si.postings[term]
+		tf := float64(len(docs[id]))
+		if tf == 0 {
+			continue
+		}
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/avgLength))
+	}
+
+	return score
+}
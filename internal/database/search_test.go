@@ -0,0 +1,65 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSearchChirps(t *testing.T) {
+	db := InitCleanDB()
+	db.CreateUserWith(UserParams{Email: "a@x.y", Handle: "alice"})
+	db.CreateUserWith(UserParams{Email: "b@x.y", Handle: "bob"})
+	db.CreateChirp(1, "The quick brown fox")
+	db.CreateChirp(2, "a BROWN dog, a brown cat and a brown cow")
+	db.CreateChirp(1, "fox brown")
+	db.CreateChirp(2, "Straße works, ΛΌΓΟΣ")
+
+	tests := []struct {
+		query string
+		ids   []int
+	}{
+		// The short chirp outranks repeats in a long one
+		{"brown", []int{3, 2, 1}},
+		{"Brown FOX", []int{3, 1}},
+		{`"brown fox"`, []int{1}},
+		{"brown from:1", []int{3, 1}},
+		{"from:@Bob", []int{4, 2}},
+		{"from:nobody", []int{}},
+		{"STRASSE", []int{4}},
+		{"λόγος", []int{4}},
+		{"zebra", []int{}},
+	}
+
+	for _, tt := range tests {
+		page, err := db.SearchChirps(tt.query, ChirpQuery{})
+		if err != nil {
+			t.Fatalf("%q: %s", tt.query, err)
+		}
+		if !slices.Equal(pageIDs(page), tt.ids) {
+			t.Errorf("%q: expected %v, got %v", tt.query, tt.ids, pageIDs(page))
+		}
+	}
+
+	page, _ := db.SearchChirps("brown", ChirpQuery{Limit: 2})
+	if page.Next != 2 {
+		t.Errorf("expected next offset 2, got %d", page.Next)
+	}
+	page, _ = db.SearchChirps("brown", ChirpQuery{Limit: 2, After: page.Next})
+	if !slices.Equal(pageIDs(page), []int{1}) || page.Next != 0 {
+		t.Errorf("expected last page [1], got %v next %d", pageIDs(page), page.Next)
+	}
+
+	// Edits and deletes update the index
+	db.EditChirp(1, 1, "slow grey wolf")
+	db.DeleteChirp(3)
+	page, _ = db.SearchChirps("fox", ChirpQuery{})
+	if len(page.Chirps) != 0 {
+		t.Errorf("expected no fox, got %v", pageIDs(page))
+	}
+
+	_, err := db.SearchChirps("  ", ChirpQuery{})
+	if !errors.Is(err, ErrEmptySearch) {
+		t.Errorf("expected ErrEmptySearch, got %v", err)
+	}
+}
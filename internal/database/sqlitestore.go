@@ -0,0 +1,585 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	_ "modernc.org/sqlite"
+)
+
+// Rows keep the full record as JSON in data so new fields don't need a
+// schema change, with the columns needed for lookups alongside.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS counters (
+	name  TEXT PRIMARY KEY,
+	value INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS chirps (
+	id        INTEGER PRIMARY KEY,
+	author_id INTEGER NOT NULL,
+	data      TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS chirps_author_id ON chirps (author_id);
+CREATE TABLE IF NOT EXISTS revisions (
+	chirp_id INTEGER NOT NULL,
+	number   INTEGER NOT NULL,
+	data     TEXT NOT NULL,
+	PRIMARY KEY (chirp_id, number)
+);
+CREATE TABLE IF NOT EXISTS users (
+	id    INTEGER PRIMARY KEY,
+	email TEXT NOT NULL,
+	data  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS hashes (
+	user_id INTEGER PRIMARY KEY,
+	hash    BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	token TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS relations (
+	kind TEXT NOT NULL,
+	src  INTEGER NOT NULL,
+	dst  INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (kind, src, dst)
+);
+CREATE TABLE IF NOT EXISTS media (
+	id       INTEGER PRIMARY KEY,
+	owner_id INTEGER NOT NULL,
+	data     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS drafts (
+	id        INTEGER PRIMARY KEY,
+	author_id INTEGER NOT NULL,
+	data      TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS lists (
+	id       INTEGER PRIMARY KEY,
+	owner_id INTEGER NOT NULL,
+	data     TEXT NOT NULL
+);
+`
+
+// sqliteStore writes each mutation as a row change in an embedded SQLite file
+type sqliteStore struct {
+	conn *sql.DB
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows one writer at a time anyway
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(sqliteSchema)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{conn: conn}, nil
+}
+
+func (s *sqliteStore) Load() (*Database, error) {
+	err := s.migrate()
+	if err != nil {
+		return nil, err
+	}
+
+	database := newDatabase()
+
+	// Counters
+	rows, err := s.conn.Query(`SELECT name, value FROM counters`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var value int
+		err = rows.Scan(&name, &value)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		switch name {
+		case "nextcid":
+			database.NextCID = value
+		case "nextuid":
+			database.NextUID = value
+		case "nextmid":
+			database.NextMID = value
+		case "nextdid":
+			database.NextDID = value
+		case "nextlid":
+			database.NextLID = value
+		}
+	}
+	rows.Close()
+
+	// Chirps
+	rows, err = s.conn.Query(`SELECT data FROM chirps`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		chirp := Chirp{}
+		err = scanJSON(rows, &chirp)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Chirps[chirp.Id] = chirp
+	}
+	rows.Close()
+
+	// Revisions
+	rows, err = s.conn.Query(`SELECT data FROM revisions ORDER BY chirp_id, number`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		rev := Revision{}
+		err = scanJSON(rows, &rev)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Revisions[rev.ChirpId] = append(database.Revisions[rev.ChirpId], rev)
+	}
+	rows.Close()
+
+	// Users
+	rows, err = s.conn.Query(`SELECT data FROM users`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		user := User{}
+		err = scanJSON(rows, &user)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Users[user.Id] = user
+	}
+	rows.Close()
+
+	// Hashes
+	rows, err = s.conn.Query(`SELECT user_id, hash FROM hashes`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var hash []byte
+		err = rows.Scan(&id, &hash)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Hashes[id] = hash
+	}
+	rows.Close()
+
+	// Refresh tokens
+	rows, err = s.conn.Query(`SELECT token FROM refresh_tokens`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var token string
+		err = rows.Scan(&token)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.RefreshTokens[token] = true
+	}
+	rows.Close()
+
+	// Relations
+	rows, err = s.conn.Query(`SELECT data FROM relations`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		rel := Relation{}
+		err = scanJSON(rows, &rel)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Relations[rel.key()] = rel
+	}
+	rows.Close()
+
+	// Media
+	rows, err = s.conn.Query(`SELECT data FROM media`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		m := Media{}
+		err = scanJSON(rows, &m)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Media[m.Id] = m
+	}
+	rows.Close()
+
+	// Drafts
+	rows, err = s.conn.Query(`SELECT data FROM drafts`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		d := Draft{}
+		err = scanJSON(rows, &d)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Drafts[d.Id] = d
+	}
+	rows.Close()
+
+	// Lists
+	rows, err = s.conn.Query(`SELECT data FROM lists`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		l := List{}
+		err = scanJSON(rows, &l)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		database.Lists[l.Id] = l
+	}
+	rows.Close()
+
+	return database, nil
+}
+
+func (s *sqliteStore) SaveChirp(chirp Chirp) error {
+	dat, err := json.Marshal(chirp)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO chirps (id, author_id, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET author_id = excluded.author_id, data = excluded.data`,
+		chirp.Id, chirp.AuthorId, string(dat))
+	if err != nil {
+		return err
+	}
+
+	err = bumpCounter(tx, "nextcid", chirp.Id+1)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) RemoveChirp(id int) error {
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM chirps WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM revisions WHERE chirp_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) SaveRevision(rev Revision) error {
+	dat, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+
+	// Revisions are immutable
+	_, err = s.conn.Exec(`INSERT OR IGNORE INTO revisions (chirp_id, number, data) VALUES (?, ?, ?)`,
+		rev.ChirpId, rev.Number, string(dat))
+	return err
+}
+
+func (s *sqliteStore) SaveUser(user User) error {
+	dat, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO users (id, email, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET email = excluded.email, data = excluded.data`,
+		user.Id, user.Email, string(dat))
+	if err != nil {
+		return err
+	}
+
+	err = bumpCounter(tx, "nextuid", user.Id+1)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) SaveHash(userID int, hash []byte) error {
+	_, err := s.conn.Exec(`INSERT INTO hashes (user_id, hash) VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET hash = excluded.hash`, userID, hash)
+	return err
+}
+
+func (s *sqliteStore) SaveRefreshToken(token string) error {
+	_, err := s.conn.Exec(`INSERT OR IGNORE INTO refresh_tokens (token) VALUES (?)`, token)
+	return err
+}
+
+func (s *sqliteStore) RemoveRefreshToken(token string) error {
+	_, err := s.conn.Exec(`DELETE FROM refresh_tokens WHERE token = ?`, token)
+	return err
+}
+
+func (s *sqliteStore) SaveRelation(rel Relation) error {
+	dat, err := json.Marshal(rel)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.conn.Exec(`INSERT INTO relations (kind, src, dst, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (kind, src, dst) DO UPDATE SET data = excluded.data`,
+		rel.Kind, rel.From, rel.To, string(dat))
+	return err
+}
+
+func (s *sqliteStore) RemoveRelation(rel Relation) error {
+	_, err := s.conn.Exec(`DELETE FROM relations WHERE kind = ? AND src = ? AND dst = ?`,
+		rel.Kind, rel.From, rel.To)
+	return err
+}
+
+func (s *sqliteStore) SaveMedia(m Media) error {
+	dat, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO media (id, owner_id, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET owner_id = excluded.owner_id, data = excluded.data`,
+		m.Id, m.OwnerId, string(dat))
+	if err != nil {
+		return err
+	}
+
+	err = bumpCounter(tx, "nextmid", m.Id+1)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) RemoveMedia(id int) error {
+	_, err := s.conn.Exec(`DELETE FROM media WHERE id = ?`, id)
+	return err
+}
+
+func (s *sqliteStore) SaveDraft(d Draft) error {
+	dat, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO drafts (id, author_id, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET author_id = excluded.author_id, data = excluded.data`,
+		d.Id, d.AuthorId, string(dat))
+	if err != nil {
+		return err
+	}
+
+	err = bumpCounter(tx, "nextdid", d.Id+1)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) RemoveDraft(id int) error {
+	_, err := s.conn.Exec(`DELETE FROM drafts WHERE id = ?`, id)
+	return err
+}
+
+func (s *sqliteStore) SaveList(l List) error {
+	dat, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO lists (id, owner_id, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET owner_id = excluded.owner_id, data = excluded.data`,
+		l.Id, l.OwnerId, string(dat))
+	if err != nil {
+		return err
+	}
+
+	err = bumpCounter(tx, "nextlid", l.Id+1)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteStore) RemoveList(id int) error {
+	_, err := s.conn.Exec(`DELETE FROM lists WHERE id = ?`, id)
+	return err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.conn.Close()
+}
+
+// migrate upgrades the JSON rows to SchemaVersion, tracked in the SQLite
+// user_version. The rows are migrated together as one document, so
+// migrations can look across collections.
+func (s *sqliteStore) migrate() error {
+	var version int
+	err := s.conn.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version == SchemaVersion {
+		return nil
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	tx, err := s.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	collections := []string{"chirps", "users"}
+	doc := map[string]any{"version": version}
+	for _, collection := range collections {
+		rows, err := tx.Query(fmt.Sprintf(`SELECT id, data FROM %s`, collection))
+		if err != nil {
+			return err
+		}
+
+		records := map[string]any{}
+		for rows.Next() {
+			var id int
+			var data string
+			err = rows.Scan(&id, &data)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			var record any
+			err = json.Unmarshal([]byte(data), &record)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			records[strconv.Itoa(id)] = record
+		}
+		rows.Close()
+		doc[collection] = records
+	}
+
+	_, err = migrateDoc(doc)
+	if err != nil {
+		return err
+	}
+
+	for _, collection := range collections {
+		for id, record := range doc[collection].(map[string]any) {
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(fmt.Sprintf(`UPDATE %s SET data = ? WHERE id = ?`, collection), string(data), id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, SchemaVersion))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// bumpCounter raises a counter to at least value
+func bumpCounter(tx *sql.Tx, name string, value int) error {
+	_, err := tx.Exec(`INSERT INTO counters (name, value) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = max(value, excluded.value)`, name, value)
+	return err
+}
+
+func scanJSON(rows *sql.Rows, v any) error {
+	var data string
+	err := rows.Scan(&data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), v)
+}
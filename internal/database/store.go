@@ -0,0 +1,72 @@
+package database
+
+import "errors"
+
+var ErrStoreDegraded error = errors.New("database writes are failing, recent changes may not be saved")
+
+// Store persists the state held by a DB.
+//
+// The DB keeps its working copy of the Database in memory and reports every
+// mutation to its Store while holding the write lock, so implementations see
+// mutations in the order they were applied. An error from a Save or Remove
+// means that mutation may not be persisted. Stores writing in the background
+// queue the mutation either way and fail with ErrStoreDegraded while their
+// writes are failing.
+type Store interface {
+	// Load reads the persisted state
+	Load() (*Database, error)
+
+	SaveChirp(chirp Chirp) error
+	// RemoveChirp also removes the revisions of the chirp
+	RemoveChirp(id int) error
+	SaveRevision(rev Revision) error
+
+	SaveUser(user User) error
+	SaveHash(userID int, hash []byte) error
+
+	SaveRefreshToken(token string) error
+	RemoveRefreshToken(token string) error
+
+	SaveRelation(rel Relation) error
+	RemoveRelation(rel Relation) error
+
+	SaveMedia(m Media) error
+	RemoveMedia(id int) error
+
+	SaveDraft(d Draft) error
+	RemoveDraft(id int) error
+
+	SaveList(l List) error
+	// RemoveList leaves the member relations to be removed on their own
+	RemoveList(id int) error
+
+	// Close releases the store, persisting anything still pending
+	Close() error
+}
+
+// Storage backends selectable by config
+const (
+	StorageJSON   = "json"
+	StorageSQLite = "sqlite"
+)
+
+// nopStore keeps nothing, used for the in-memory debug database
+type nopStore struct{}
+
+func (nopStore) Load() (*Database, error)        { return newDatabase(), nil }
+func (nopStore) SaveChirp(Chirp) error           { return nil }
+func (nopStore) SaveRevision(Revision) error     { return nil }
+func (nopStore) RemoveChirp(int) error           { return nil }
+func (nopStore) SaveUser(User) error             { return nil }
+func (nopStore) SaveHash(int, []byte) error      { return nil }
+func (nopStore) SaveRefreshToken(string) error   { return nil }
+func (nopStore) RemoveRefreshToken(string) error { return nil }
+func (nopStore) SaveRelation(Relation) error     { return nil }
+func (nopStore) RemoveRelation(Relation) error   { return nil }
+func (nopStore) SaveMedia(Media) error           { return nil }
+func (nopStore) RemoveMedia(int) error           { return nil }
+func (nopStore) SaveDraft(Draft) error           { return nil }
+func (nopStore) RemoveDraft(int) error           { return nil }
+func (nopStore) SaveList(List) error             { return nil }
+func (nopStore) RemoveList(int) error            { return nil }
+func (nopStore) Close() error                    { return nil }
@@ -0,0 +1,238 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoresRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "database.json")
+	err := os.WriteFile(jsonPath, []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := map[string]string{
+		StorageJSON:   jsonPath,
+		StorageSQLite: filepath.Join(dir, "database.sqlite"),
+	}
+
+	for storage, path := range backends {
+		db, err := InitStorageDB(storage, path)
+		if err != nil {
+			t.Fatalf("%s: %s", storage, err)
+		}
+
+		user, err := db.CreateUser("a@b.c", "password")
+		if err != nil {
+			t.Fatalf("%s: %s", storage, err)
+		}
+		chirp, err := db.CreateChirp(user.Id, "hello")
+		if err != nil {
+			t.Fatalf("%s: %s", storage, err)
+		}
+		db.AddRefreshToken("token")
+		other, _ := db.CreateUser("d@e.f", "password")
+		db.Follow(other.Id, user.Id)
+		m, _ := db.CreateMedia(Media{OwnerId: user.Id, Hash: "aa"})
+		poll, _ := db.CreateChirpWith(user.Id, ChirpParams{Poll: &Poll{Options: []string{"a", "b"}, ExpiresAt: time.Now().Add(time.Hour)}})
+		db.Vote(other.Id, poll.Id, 1)
+		list, _ := db.CreateList(other.Id, ListParams{Name: "friends", Private: true})
+		db.AddListMember(list.Id, other.Id, user.Id)
+
+		err = db.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", storage, err)
+		}
+
+		db, err = InitStorageDB(storage, path)
+		if err != nil {
+			t.Fatalf("%s: %s", storage, err)
+		}
+
+		got, err := db.GetChirp(chirp.Id)
+		if err != nil || got.Body != "hello" || got.AuthorId != user.Id {
+			t.Errorf("%s: chirp not persisted, got %v, %v", storage, got, err)
+		}
+		if _, ok := db.ValidLogin("a@b.c", "password"); !ok {
+			t.Errorf("%s: user login not persisted", storage)
+		}
+		if !db.IsValidRefreshToken("token") {
+			t.Errorf("%s: refresh token not persisted", storage)
+		}
+		timeline, _ := db.GetTimeline(other.Id, ChirpQuery{})
+		if len(timeline.Chirps) != 2 {
+			t.Errorf("%s: follow not persisted, got %v", storage, timeline.Chirps)
+		}
+		if got, err := db.GetMedia(m.Id); err != nil || got.Hash != "aa" {
+			t.Errorf("%s: media not persisted, got %v, %v", storage, got, err)
+		}
+		poll, _ = db.GetChirp(poll.Id)
+		if view := db.ViewChirp(poll, other.Id); view.Poll == nil || view.Poll.MyVote == nil || *view.Poll.MyVote != 1 {
+			t.Errorf("%s: poll vote not persisted, got %+v", storage, view.Poll)
+		}
+		if page, err := db.GetListTimeline(list.Id, other.Id, ChirpQuery{}); err != nil || len(page.Chirps) != 2 {
+			t.Errorf("%s: list not persisted, got %v, %v", storage, page.Chirps, err)
+		}
+		if l2, _ := db.CreateList(other.Id, ListParams{Name: "more"}); l2.Id != list.Id+1 {
+			t.Errorf("%s: list id not continued, got %d", storage, l2.Id)
+		}
+		if m2, _ := db.CreateMedia(Media{OwnerId: user.Id, Hash: "bb"}); m2.Id != m.Id+1 {
+			t.Errorf("%s: media id not continued, got %d", storage, m2.Id)
+		}
+
+		next, err := db.CreateChirp(user.Id, "again")
+		if err != nil || next.Id != poll.Id+1 {
+			t.Errorf("%s: chirp id not continued, got %d", storage, next.Id)
+		}
+
+		db.Close()
+	}
+}
+
+func TestJSONStoreFlushesBurstOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	err := os.WriteFile(path, []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := db.CreateChirp(1, "burst")
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chirps, err := db.GetChirps()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chirps) != 50 {
+		t.Errorf("expected 50 chirps on disk, got %d", len(chirps))
+	}
+}
+
+func TestJSONStoreDegradedUntilWritesRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	err := os.WriteFile(path, []byte("{}"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { db.Close() }()
+
+	// A directory where the log should be makes every append fail
+	os.Remove(path + ".log")
+	err = os.Mkdir(path+".log", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor := func(want bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			_, err := db.CreateChirp(1, "chirp")
+			if errors.Is(err, ErrStoreDegraded) == want {
+				return
+			}
+			time.Sleep(2 * jsonWriteDelay)
+		}
+		t.Fatalf("expected degraded to be %v", want)
+	}
+	waitFor(true)
+
+	// The queued records are retried once the log can be written again
+	os.Remove(path + ".log")
+	waitFor(false)
+
+	chirps, _ := db.GetChirps()
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err = InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := db.GetChirps()
+	if len(saved) != len(chirps) {
+		t.Errorf("expected %d chirps on disk, got %d", len(chirps), len(saved))
+	}
+}
+
+func TestWatchReloadsExternalEdits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	err := os.WriteFile(path, []byte(`{"version": 1}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ours, err := db.CreateChirp(1, "ours")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.Watch(10 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := `{"version": 1, "nextcid": 101, "chirps": {"100": {"body": "theirs", "id": 100, "author_id": 2}}}`
+	err = os.WriteFile(path, []byte(edited), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := db.GetChirp(100); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := db.GetChirp(100); err != nil {
+		t.Fatal("external edit was not reloaded")
+	}
+	if _, err := db.GetChirp(ours.Id); err != nil {
+		t.Error("reload dropped a chirp created before the edit")
+	}
+}
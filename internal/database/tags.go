@@ -0,0 +1,125 @@
+package database
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTrendingWindow = 24 * time.Hour
+	MaxTrendingWindow     = 7 * 24 * time.Hour
+
+	DefaultTrendingLimit = 10
+	MaxTrendingLimit     = 50
+)
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TagKey normalizes a tag or handle from a path, with or without its sigil,
+// the way chirp bodies are indexed
+func TagKey(tag string) string {
+	return strings.ToLower(strings.TrimLeft(tag, "#@"))
+}
+
+// GetTagChirps returns a page of the chirps using #tag
+func (db *DB) GetTagChirps(tag string, q ChirpQuery) (ChirpPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.pageChirps(db.index.chirpsByTag[TagKey(tag)], q), nil
+}
+
+// GetMentions returns a page of the chirps mentioning userID, under
+// whichever handle they had when the chirp was written
+func (db *DB) GetMentions(userID int, q ChirpQuery) (ChirpPage, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.pageChirps(db.index.chirpsByMention[userID], q), nil
+}
+
+// mentionedUsers resolves the @handles in body to the ids of their current
+// owners, must hold the lock
+func (db *DB) mentionedUsers(body string) []int {
+	ids := []int{}
+	for _, handle := range ExtractMentions(body) {
+		id, ok := db.index.usersByHandle[handleKey(handle)]
+		if ok && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// TrendingTags counts the chirps using each tag posted within window of now
+// that viewerID would see listed, most used first. 0 is an anonymous viewer.
+func (db *DB) TrendingTags(window time.Duration, limit int, viewerID int) []TagCount {
+	if window <= 0 {
+		window = DefaultTrendingWindow
+	}
+	window = min(window, MaxTrendingWindow)
+	if limit <= 0 {
+		limit = DefaultTrendingLimit
+	}
+	limit = min(limit, MaxTrendingLimit)
+
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	// Ids ascend with creation time, so the window is a suffix of the ids
+	since := time.Now().Add(-window)
+	ids := db.index.chirpIDs
+	start := sort.Search(len(ids), func(i int) bool {
+		return db.database.Chirps[ids[i]].CreatedAt.After(since)
+	})
+
+	filter := db.filterFor(viewerID)
+	counts := map[string]int{}
+	for _, id := range ids[start:] {
+		// Only public chirps trend, others would leak their tags
+		chirp := db.database.Chirps[id]
+		if chirp.Visibility != VisibilityPublic || !filter.listed(chirp) {
+			continue
+		}
+		for _, tag := range ExtractTags(chirp.Body) {
+			counts[tag]++
+		}
+	}
+
+	trending := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		trending = append(trending, TagCount{Tag: tag, Count: count})
+	}
+	slices.SortFunc(trending, func(a, b TagCount) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Tag, b.Tag)
+	})
+
+	return trending[:min(len(trending), limit)]
+}
+
+// addEntities indexes the tags and mentions of a live chirp
+func (ix *indexes) addEntities(chirp Chirp) {
+	for _, tag := range ExtractTags(chirp.Body) {
+		ix.chirpsByTag[tag] = insertSorted(ix.chirpsByTag[tag], chirp.Id)
+	}
+	for _, userID := range chirp.Mentions {
+		ix.chirpsByMention[userID] = insertSorted(ix.chirpsByMention[userID], chirp.Id)
+	}
+}
+
+func (ix *indexes) removeEntities(chirp Chirp) {
+	for _, tag := range ExtractTags(chirp.Body) {
+		removeKeyed(ix.chirpsByTag, tag, chirp.Id)
+	}
+	for _, userID := range chirp.Mentions {
+		removeKeyed(ix.chirpsByMention, userID, chirp.Id)
+	}
+}
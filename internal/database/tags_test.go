@@ -0,0 +1,90 @@
+package database
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExtractEntities(t *testing.T) {
+	tests := []struct {
+		body     string
+		tags     []string
+		mentions []string
+	}{
+		{"hello #Go and #go again", []string{"go"}, []string{}},
+		{"#café au lait, #日本語!", []string{"café", "日本語"}, []string{}},
+		{"#1 is not a tag but #2024年 is", []string{"2024年"}, []string{}},
+		{"mail me@example.com or ping @Alice_B.", []string{}, []string{"alice_b"}},
+		{"no#tag here, #a#b", []string{"a"}, []string{}},
+		{"(@bob) @bob @", []string{}, []string{"bob"}},
+	}
+
+	for _, tt := range tests {
+		tags := ExtractTags(tt.body)
+		if !slices.Equal(tags, tt.tags) {
+			t.Errorf("%q: expected tags %v, got %v", tt.body, tt.tags, tags)
+		}
+		mentions := ExtractMentions(tt.body)
+		if !slices.Equal(mentions, tt.mentions) {
+			t.Errorf("%q: expected mentions %v, got %v", tt.body, tt.mentions, mentions)
+		}
+	}
+}
+
+func TestTagFeeds(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	db.CreateChirp(1, "#go is fun @alice")
+	db.CreateChirp(1, "more #Go")
+	db.CreateChirp(2, "#rust")
+
+	page, _ := db.GetTagChirps("#GO", ChirpQuery{})
+	if !slices.Equal(pageIDs(page), []int{1, 2}) {
+		t.Errorf("expected [1 2], got %v", pageIDs(page))
+	}
+
+	page, _ = db.GetMentions(alice.Id, ChirpQuery{})
+	if !slices.Equal(pageIDs(page), []int{1}) {
+		t.Errorf("expected [1], got %v", pageIDs(page))
+	}
+
+	// Mentions follow the user, not the handle
+	handle := "alice2"
+	db.UpdateProfile(alice.Id, ProfileUpdate{Handle: &handle})
+	db.CreateChirp(2, "@alice is gone")
+	page, _ = db.GetMentions(alice.Id, ChirpQuery{})
+	if !slices.Equal(pageIDs(page), []int{1}) {
+		t.Errorf("expected [1] after the handle change, got %v", pageIDs(page))
+	}
+
+	db.EditChirp(2, 1, "more #rust")
+	db.DeleteChirp(1)
+	page, _ = db.GetTagChirps("go", ChirpQuery{})
+	if len(page.Chirps) != 0 {
+		t.Errorf("expected no #go chirps, got %v", pageIDs(page))
+	}
+
+	trending := db.TrendingTags(time.Hour, 0, 0)
+	if !slices.Equal(trending, []TagCount{{"rust", 2}}) {
+		t.Errorf("expected rust trending, got %v", trending)
+	}
+
+	// Chirps the viewer wouldn't see listed don't count for them
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+	db.Mute(alice.Id, bob.Id)
+	if trending := db.TrendingTags(time.Hour, 0, alice.Id); !slices.Equal(trending, []TagCount{{"rust", 1}}) {
+		t.Errorf("expected muted chirps left out, got %v", trending)
+	}
+
+	// Chirps outside the window don't count
+	chirp := db.database.Chirps[3]
+	chirp.CreatedAt = chirp.CreatedAt.Add(-2 * time.Hour)
+	db.database.Chirps[3] = chirp
+	chirp = db.database.Chirps[2]
+	chirp.CreatedAt = chirp.CreatedAt.Add(-2 * time.Hour)
+	db.database.Chirps[2] = chirp
+	if trending := db.TrendingTags(time.Hour, 0, 0); len(trending) != 0 {
+		t.Errorf("expected nothing trending, got %v", trending)
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+const (
+	// Levels of replies nested under each reply by default
+	DefaultThreadDepth = 3
+	MaxThreadDepth     = 10
+
+	// Replies nested under each reply, oldest first. The rest are paged
+	// through the thread of that reply.
+	NestedReplyLimit = 20
+)
+
+// Thread is a conversation around one chirp
+type Thread struct {
+	Chirp ChirpView `json:"chirp"`
+
+	// Chirps replied to, the conversation root first. Stops early when a
+	// parent has been purged.
+	Ancestors []ChirpView `json:"ancestors"`
+
+	// One page of direct replies with their own replies nested below
+	Replies []ThreadNode `json:"replies"`
+
+	// Cursor for the following page of replies, 0 when this is the last
+	Next int `json:"-"`
+}
+
+type ThreadNode struct {
+	ChirpView
+
+	// Number of direct replies the viewer can see, including any not
+	// nested below
+	ReplyCount int          `json:"reply_count"`
+	Replies    []ThreadNode `json:"replies,omitempty"`
+}
+
+// GetThread returns the ancestors of a chirp and a page of its replies, each
+// nesting replies up to depth levels, as seen by viewerID. Deleted chirps
+// appear as tombstones so replies to them stay readable, replies hidden from
+// the viewer are left out.
+func (db *DB) GetThread(cid int, q ChirpQuery, depth int, viewerID int) (Thread, error) {
+	if depth <= 0 {
+		depth = DefaultThreadDepth
+	}
+	depth = min(depth, MaxThreadDepth)
+
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	filter := db.filterFor(viewerID)
+	q.ViewerID = viewerID
+
+	chirp, ok := db.database.Chirps[cid]
+	if !ok || !filter.shown(chirp) {
+		return Thread{}, ErrChirpNotFound
+	}
+
+	thread := Thread{
+		Chirp:     db.viewChirp(tombstone(chirp), viewerID),
+		Ancestors: []ChirpView{},
+		Replies:   []ThreadNode{},
+	}
+
+	// Parents always have lower ids, so the chain ends
+	for parentID := chirp.InReplyTo; parentID != 0; {
+		parent, ok := db.database.Chirps[parentID]
+		if !ok {
+			break
+		}
+		thread.Ancestors = append(thread.Ancestors, db.threadAncestor(parent, filter, viewerID))
+		parentID = parent.InReplyTo
+	}
+	for i, j := 0, len(thread.Ancestors)-1; i < j; i, j = i+1, j-1 {
+		thread.Ancestors[i], thread.Ancestors[j] = thread.Ancestors[j], thread.Ancestors[i]
+	}
+
+	page := db.pageChirps(db.index.repliesByParent[cid], q)
+	for _, reply := range page.Chirps {
+		thread.Replies = append(thread.Replies, db.threadNode(reply, depth-1, viewerID))
+	}
+	thread.Next = page.Next
+
+	return thread, nil
+}
+
+// threadNode nests replies to chirp up to depth levels, must hold the lock
+func (db *DB) threadNode(chirp Chirp, depth int, viewerID int) ThreadNode {
+	ids := db.listedIDs(db.index.repliesByParent[chirp.Id], db.filterFor(viewerID))
+	node := ThreadNode{ChirpView: db.viewChirp(tombstone(chirp), viewerID), ReplyCount: len(ids)}
+	if depth <= 0 {
+		return node
+	}
+
+	for _, id := range ids[:min(len(ids), NestedReplyLimit)] {
+		reply, ok := db.database.Chirps[id]
+		if !ok {
+			continue
+		}
+		node.Replies = append(node.Replies, db.threadNode(reply, depth-1, viewerID))
+	}
+
+	return node
+}
+
+// threadAncestor shows a chirp replied to, hiding the content of one the
+// viewer can't see. Must hold the lock.
+func (db *DB) threadAncestor(chirp Chirp, filter chirpFilter, viewerID int) ChirpView {
+	if filter.shown(chirp) {
+		return db.viewChirp(tombstone(chirp), viewerID)
+	}
+
+	return ChirpView{Chirp: Chirp{Id: chirp.Id, InReplyTo: chirp.InReplyTo}, Unavailable: true}
+}
+
+// tombstone hides the content of a deleted chirp, keeping its place
+func tombstone(chirp Chirp) Chirp {
+	if chirp.DeletedAt == nil {
+		return chirp
+	}
+
+	return Chirp{
+		Id:        chirp.Id,
+		CreatedAt: chirp.CreatedAt,
+		InReplyTo: chirp.InReplyTo,
+		DeletedAt: chirp.DeletedAt,
+	}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func nodeIDs(nodes []ThreadNode) []int {
+	ids := []int{}
+	for _, node := range nodes {
+		ids = append(ids, node.Id)
+	}
+	return ids
+}
+
+func viewIDs(views []ChirpView) []int {
+	ids := []int{}
+	for _, view := range views {
+		ids = append(ids, view.Id)
+	}
+	return ids
+}
+
+func TestGetThread(t *testing.T) {
+	db := InitCleanDB()
+
+	// 1 <- 2 <- 3 <- 4, and 5 replying to 2
+	root, _ := db.CreateChirp(1, "root")
+	parentID := root.Id
+	for i := 0; i < 3; i++ {
+		reply, err := db.CreateChirpWith(2, ChirpParams{Body: "reply", InReplyTo: parentID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parentID = reply.Id
+	}
+	db.CreateChirpWith(3, ChirpParams{Body: "side", InReplyTo: 2})
+
+	thread, err := db.GetThread(3, ChirpQuery{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(viewIDs(thread.Ancestors), []int{1, 2}) {
+		t.Errorf("expected ancestors [1 2], got %v", thread.Ancestors)
+	}
+	if !slices.Equal(nodeIDs(thread.Replies), []int{4}) {
+		t.Errorf("expected replies [4], got %v", nodeIDs(thread.Replies))
+	}
+
+	thread, _ = db.GetThread(1, ChirpQuery{}, 1, 0)
+	if len(thread.Replies) != 1 || thread.Replies[0].ReplyCount != 2 || thread.Replies[0].Replies != nil {
+		t.Errorf("expected one reply with 2 unnested replies, got %+v", thread.Replies)
+	}
+
+	thread, _ = db.GetThread(2, ChirpQuery{Limit: 1}, 0, 0)
+	if !slices.Equal(nodeIDs(thread.Replies), []int{3}) || thread.Next != 3 {
+		t.Errorf("expected page [3] next 3, got %v next %d", nodeIDs(thread.Replies), thread.Next)
+	}
+
+	_, err = db.CreateChirpWith(1, ChirpParams{Body: "lost", InReplyTo: 99})
+	if !errors.Is(err, ErrReplyTargetNotFound) {
+		t.Errorf("expected ErrReplyTargetNotFound, got %v", err)
+	}
+}
+
+func TestThreadOrphans(t *testing.T) {
+	db := InitCleanDB()
+
+	root, _ := db.CreateChirp(1, "root")
+	reply, _ := db.CreateChirpWith(2, ChirpParams{Body: "reply", InReplyTo: root.Id})
+	db.DeleteChirp(root.Id)
+
+	thread, err := db.GetThread(reply.Id, ChirpQuery{}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread.Ancestors) != 1 || thread.Ancestors[0].DeletedAt == nil || thread.Ancestors[0].Body != "" {
+		t.Errorf("expected tombstone ancestor, got %+v", thread.Ancestors)
+	}
+
+	thread, _ = db.GetThread(root.Id, ChirpQuery{}, 0, 0)
+	if !slices.Equal(nodeIDs(thread.Replies), []int{reply.Id}) {
+		t.Errorf("expected reply under deleted root, got %v", nodeIDs(thread.Replies))
+	}
+
+	db.purgeChirp(db.database.Chirps[root.Id])
+	thread, err = db.GetThread(reply.Id, ChirpQuery{}, 0, 0)
+	if err != nil || len(thread.Ancestors) != 0 || thread.Chirp.Body != "reply" {
+		t.Errorf("expected readable orphan, got %+v, %v", thread, err)
+	}
+}
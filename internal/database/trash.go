@@ -0,0 +1,107 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// How long deleted chirps stay restorable by default
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+var ErrChirpNotDeleted error = errors.New("chirp is not deleted")
+var ErrTrashExpired error = errors.New("chirp is past the trash retention")
+
+// SetTrashRetention sets how long deleted chirps can be restored before
+// they are purged
+func (db *DB) SetTrashRetention(retention time.Duration) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.trashRetention = retention
+}
+
+// RestoreChirp takes one of the author's chirps back out of the trash
+func (db *DB) RestoreChirp(cid int, userID int) (Chirp, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.database.Chirps[cid]
+	if !ok {
+		return Chirp{}, ErrChirpNotFound
+	}
+	if chirp.AuthorId != userID {
+		return Chirp{}, ErrNotAuthorized
+	}
+	if chirp.DeletedAt == nil {
+		return Chirp{}, ErrChirpNotDeleted
+	}
+	if time.Since(*chirp.DeletedAt) > db.trashRetention {
+		return Chirp{}, ErrTrashExpired
+	}
+
+	chirp.DeletedAt = nil
+	db.database.Chirps[cid] = chirp
+	db.index.untrashChirp(chirp)
+
+	return chirp, db.store.SaveChirp(chirp)
+}
+
+// GetTrash lists a user's deleted chirps that can still be restored
+func (db *DB) GetTrash(userID int) ([]Chirp, error) {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	cutoff := time.Now().Add(-db.trashRetention)
+	chirps := []Chirp{}
+	for _, cid := range db.index.trashByAuthor[userID] {
+		chirp := db.database.Chirps[cid]
+		if chirp.DeletedAt.After(cutoff) {
+			chirps = append(chirps, chirp)
+		}
+	}
+
+	return chirps, nil
+}
+
+// PurgeExpired permanently removes chirps that have been in the trash longer
+// than the retention, returning how many were removed
+func (db *DB) PurgeExpired() (int, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	cutoff := time.Now().Add(-db.trashRetention)
+	expired := []Chirp{}
+	for _, ids := range db.index.trashByAuthor {
+		for _, cid := range ids {
+			chirp := db.database.Chirps[cid]
+			if chirp.DeletedAt.Before(cutoff) {
+				expired = append(expired, chirp)
+			}
+		}
+	}
+
+	for _, chirp := range expired {
+		err := db.purgeChirp(chirp)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(expired), nil
+}
+
+// purgeChirp removes a chirp and its history for good, must hold the lock
+func (db *DB) purgeChirp(chirp Chirp) error {
+	for _, kind := range []string{RelationLike, RelationRechirp, RelationVote, RelationBookmark, RelationPin} {
+		err := db.removeRelationsTo(kind, chirp.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	db.index.purgeChirp(chirp)
+	delete(db.database.Chirps, chirp.Id)
+	delete(db.database.Revisions, chirp.Id)
+
+	return db.store.RemoveChirp(chirp.Id)
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteAndRestoreChirp(t *testing.T) {
+	db := InitCleanDB()
+
+	chirp, err := db.CreateChirp(1, "oops")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.DeleteChirp(chirp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.GetChirp(chirp.Id)
+	if !errors.Is(err, ErrChirpDeleted) {
+		t.Errorf("expected ErrChirpDeleted, got %v", err)
+	}
+	chirps, _ := db.GetChirps()
+	if len(chirps) != 0 {
+		t.Errorf("expected deleted chirp out of listings, got %v", chirps)
+	}
+
+	trash, _ := db.GetTrash(1)
+	if len(trash) != 1 || trash[0].Id != chirp.Id {
+		t.Errorf("expected chirp in trash, got %v", trash)
+	}
+
+	_, err = db.RestoreChirp(chirp.Id, 2)
+	if !errors.Is(err, ErrNotAuthorized) {
+		t.Errorf("expected ErrNotAuthorized, got %v", err)
+	}
+
+	restored, err := db.RestoreChirp(chirp.Id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil {
+		t.Errorf("expected restored chirp, got %+v", restored)
+	}
+
+	_, err = db.RestoreChirp(chirp.Id, 1)
+	if !errors.Is(err, ErrChirpNotDeleted) {
+		t.Errorf("expected ErrChirpNotDeleted, got %v", err)
+	}
+	chirps, _ = db.GetChirpsByUserID(1)
+	if len(chirps) != 1 {
+		t.Errorf("expected restored chirp listed, got %v", chirps)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	db := InitCleanDB()
+	db.SetTrashRetention(time.Hour)
+
+	old, _ := db.CreateChirp(1, "old")
+	recent, _ := db.CreateChirp(1, "recent")
+	db.DeleteChirp(old.Id)
+	db.DeleteChirp(recent.Id)
+
+	// Pretend the first delete happened long ago
+	chirp := db.database.Chirps[old.Id]
+	deletedAt := time.Now().Add(-2 * time.Hour)
+	chirp.DeletedAt = &deletedAt
+	db.database.Chirps[old.Id] = chirp
+
+	_, err := db.RestoreChirp(old.Id, 1)
+	if !errors.Is(err, ErrTrashExpired) {
+		t.Errorf("expected ErrTrashExpired, got %v", err)
+	}
+
+	n, err := db.PurgeExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 purged, got %d", n)
+	}
+
+	_, err = db.GetChirp(old.Id)
+	if !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("expected ErrChirpNotFound, got %v", err)
+	}
+	_, err = db.GetChirp(recent.Id)
+	if !errors.Is(err, ErrChirpDeleted) {
+		t.Errorf("expected recent chirp still in trash, got %v", err)
+	}
+}
@@ -6,37 +6,75 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var ErrEmailTaken error = errors.New("email already used by another account")
+
 func (db *DB) GetUserById(id int) (*User, bool) {
 	user, ok := db.database.Users[id]
 	return &user, ok
 }
 
 func (db *DB) getUserByEmail(email string) (User, bool) {
-	for _, val := range db.database.Users {
-		if val.Email == email {
-			return val, true
-		}
+	id, ok := db.index.usersByEmail[emailKey(email)]
+	if !ok {
+		return User{}, false
 	}
 
-	return User{}, false
+	user, ok := db.database.Users[id]
+	return user, ok
 }
 
 func (db *DB) CreateUser(email string, pass string) (User, error) {
+	return db.CreateUserWith(UserParams{Email: email, Password: pass})
+}
+
+// CreateUserWith creates an account, with a default handle when none is given
+func (db *DB) CreateUserWith(params UserParams) (User, error) {
+	err := validateProfile(params.Handle, params.DisplayName, params.Bio)
+	if err != nil {
+		return User{}, err
+	}
+
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if db.emailTaken(params.Email, 0) {
+		return User{}, ErrEmailTaken
+	}
+	if params.Handle != "" && db.handleTaken(params.Handle, 0) {
+		return User{}, ErrHandleTaken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, err
 	}
 
-	user := User{Email: email, Id: db.database.NextUID, IsChirpyRed: false}
+	user := User{
+		Email:       params.Email,
+		Id:          db.database.NextUID,
+		IsChirpyRed: false,
+		Plan:        PlanFree,
+		Handle:      params.Handle,
+		DisplayName: params.DisplayName,
+		Bio:         params.Bio,
+	}
+	if user.Handle == "" {
+		user.Handle = db.defaultHandle(user.Id)
+	}
 	db.database.NextUID++
 	db.database.Users[user.Id] = user
+	db.index.setUser(User{}, user)
 
 	db.database.Hashes[user.Id] = hash
 
-	go db.writeDB()
+	err = db.store.SaveUser(user)
+	if err != nil {
+		return User{}, err
+	}
+	err = db.store.SaveHash(user.Id, hash)
+	if err != nil {
+		return User{}, err
+	}
 
 	return user, nil
 }
@@ -50,26 +88,39 @@ func (db *DB) UpdateUser(id int, email string, pass string) (User, error) {
 		return User{}, err
 	}
 
-	user := User{Email: email, Id: id}
+	user, ok := db.database.Users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	if db.emailTaken(email, id) {
+		return User{}, ErrEmailTaken
+	}
+	user.Email = email
 
+	db.index.setUser(db.database.Users[user.Id], user)
 	db.database.Users[user.Id] = user
 	db.database.Hashes[user.Id] = hash
 
+	err = db.store.SaveUser(user)
+	if err != nil {
+		return User{}, err
+	}
+	err = db.store.SaveHash(user.Id, hash)
+	if err != nil {
+		return User{}, err
+	}
+
 	return user, nil
 }
 
+// UpgradeUser moves a user onto the Chirpy Red plan
 func (db *DB) UpgradeUser(id int) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
-
-	user, ok := db.GetUserById(id)
-	if !ok {
-		return errors.New("no user found with id")
-	}
-
-	user.IsChirpyRed = true
-
-	db.database.Users[id] = *user
+	return db.SetUserPlan(id, PlanRed)
+}
 
-	return nil
+// emailTaken reports whether another user than userID has the email,
+// ignoring case. Must hold the lock.
+func (db *DB) emailTaken(email string, userID int) bool {
+	id, ok := db.index.usersByEmail[emailKey(email)]
+	return ok && id != userID
 }
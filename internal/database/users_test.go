@@ -0,0 +1,26 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmailTakenIgnoresCase(t *testing.T) {
+	db := InitCleanDB()
+	victim, _ := db.CreateUser("victim@x.com", "pw")
+	other, _ := db.CreateUser("other@x.com", "pw")
+
+	if _, err := db.CreateUser("Victim@X.com", "pw"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken on signup, got %v", err)
+	}
+	if _, err := db.UpdateUser(other.Id, " VICTIM@x.com", "pw"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken on update, got %v", err)
+	}
+	if _, err := db.UpdateUser(victim.Id, "Victim@X.com", "pw2"); err != nil {
+		t.Errorf("expected users to keep their own email, got %v", err)
+	}
+
+	if user, ok := db.ValidLogin("victim@x.com", "pw2"); !ok || user.Id != victim.Id {
+		t.Errorf("expected login to resolve to the victim, got %v", user)
+	}
+}
@@ -0,0 +1,42 @@
+package database
+
+import "errors"
+
+// Who can read a chirp. Followers chirps are for the author's followers,
+// direct chirps for the users they mention.
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityDirect    = "direct"
+)
+
+var ErrBadVisibility error = errors.New("visibility must be public, followers or direct")
+var ErrNoRecipients error = errors.New("direct chirps must mention at least one user")
+var ErrNotPublic error = errors.New("only public chirps can be rechirped")
+
+// audience checks the visibility of a new chirp by authorID and resolves the
+// recipients of a direct chirp, must hold the lock
+func (db *DB) audience(authorID int, params ChirpParams) (string, []int, error) {
+	switch params.Visibility {
+	case "", VisibilityPublic:
+		return VisibilityPublic, nil, nil
+	case VisibilityFollowers:
+		return VisibilityFollowers, nil, nil
+	case VisibilityDirect:
+	default:
+		return "", nil, ErrBadVisibility
+	}
+
+	// Later handle changes don't move a chirp to someone else
+	recipients := []int{}
+	for _, id := range db.mentionedUsers(params.Body) {
+		if id != authorID {
+			recipients = append(recipients, id)
+		}
+	}
+	if len(recipients) == 0 {
+		return "", nil, ErrNoRecipients
+	}
+
+	return VisibilityDirect, recipients, nil
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVisibility(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+	carol, _ := db.CreateUserWith(UserParams{Email: "c@x.y", Password: "pw", Handle: "carol"})
+
+	db.Follow(bob.Id, alice.Id)
+	public, _ := db.CreateChirp(alice.Id, "for everyone")
+	followers, err := db.CreateChirpWith(alice.Id, ChirpParams{Body: "for followers", Visibility: VisibilityFollowers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	direct, err := db.CreateChirpWith(alice.Id, ChirpParams{Body: "hi @carol", Visibility: VisibilityDirect})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.Visibility != VisibilityPublic || len(direct.Recipients) != 1 || direct.Recipients[0] != carol.Id {
+		t.Fatalf("unexpected chirps %v %v", public, direct)
+	}
+
+	cases := []struct {
+		viewer int
+		want   []int
+	}{
+		{0, []int{public.Id}},
+		{alice.Id, []int{public.Id, followers.Id, direct.Id}},
+		{bob.Id, []int{public.Id, followers.Id}},
+		{carol.Id, []int{public.Id, direct.Id}},
+	}
+	for _, c := range cases {
+		page, _ := db.GetChirpsPage(ChirpQuery{ViewerID: c.viewer})
+		got := pageIDs(page)
+		if len(got) != len(c.want) {
+			t.Errorf("viewer %d: expected %v, got %v", c.viewer, c.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("viewer %d: expected %v, got %v", c.viewer, c.want, got)
+				break
+			}
+		}
+	}
+
+	if _, err := db.GetVisibleChirp(followers.Id, 0); !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("expected anonymous reads to miss, got %v", err)
+	}
+	if _, err := db.GetVisibleChirp(direct.Id, bob.Id); !errors.Is(err, ErrChirpNotFound) {
+		t.Errorf("expected direct chirp hidden from bob, got %v", err)
+	}
+	if _, err := db.Rechirp(bob.Id, followers.Id); !errors.Is(err, ErrNotPublic) {
+		t.Errorf("expected ErrNotPublic, got %v", err)
+	}
+	_, err = db.CreateChirpWith(carol.Id, ChirpParams{Body: "peek", QuoteOf: followers.Id})
+	if !errors.Is(err, ErrQuoteTargetNotFound) {
+		t.Errorf("expected ErrQuoteTargetNotFound, got %v", err)
+	}
+}
+
+func TestBadVisibility(t *testing.T) {
+	db := InitCleanDB()
+	alice, _ := db.CreateUserWith(UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+
+	_, err := db.CreateChirpWith(alice.Id, ChirpParams{Body: "hi", Visibility: "friends"})
+	if !errors.Is(err, ErrBadVisibility) {
+		t.Errorf("expected ErrBadVisibility, got %v", err)
+	}
+	_, err = db.CreateChirpWith(alice.Id, ChirpParams{Body: "hi @nobody", Visibility: VisibilityDirect})
+	if !errors.Is(err, ErrNoRecipients) {
+		t.Errorf("expected ErrNoRecipients, got %v", err)
+	}
+}
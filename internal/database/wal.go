@@ -0,0 +1,206 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// Write-ahead log operations, one per Store mutation
+const (
+	opSaveChirp          = "chirp.save"
+	opRemoveChirp        = "chirp.remove"
+	opSaveRevision       = "revision.save"
+	opSaveUser           = "user.save"
+	opSaveHash           = "hash.save"
+	opSaveRefreshToken   = "token.save"
+	opRemoveRefreshToken = "token.remove"
+	opSaveRelation       = "relation.save"
+	opRemoveRelation     = "relation.remove"
+	opSaveMedia          = "media.save"
+	opRemoveMedia        = "media.remove"
+	opSaveDraft          = "draft.save"
+	opRemoveDraft        = "draft.remove"
+	opSaveList           = "list.save"
+	opRemoveList         = "list.remove"
+)
+
+// logRecord is one line of the write-ahead log. Records carry the full new
+// state of what they touch, so replaying a record twice is harmless.
+type logRecord struct {
+	Op    string `json:"op"`
+	Id    int    `json:"id,omitempty"`
+	Chirp *Chirp `json:"chirp,omitempty"`
+
+	Revision *Revision `json:"revision,omitempty"`
+	User     *User     `json:"user,omitempty"`
+	Hash     []byte    `json:"hash,omitempty"`
+	Token    string    `json:"token,omitempty"`
+	Relation *Relation `json:"relation,omitempty"`
+	Media    *Media    `json:"media,omitempty"`
+	Draft    *Draft    `json:"draft,omitempty"`
+	List     *List     `json:"list,omitempty"`
+}
+
+// apply replays a logged mutation onto the database
+func (d *Database) apply(rec logRecord) error {
+	switch rec.Op {
+	case opSaveChirp:
+		if rec.Chirp == nil {
+			return errors.New("log record missing chirp")
+		}
+		d.Chirps[rec.Chirp.Id] = *rec.Chirp
+		d.NextCID = max(d.NextCID, rec.Chirp.Id+1)
+	case opRemoveChirp:
+		delete(d.Chirps, rec.Id)
+		delete(d.Revisions, rec.Id)
+	case opSaveRevision:
+		if rec.Revision == nil {
+			return errors.New("log record missing revision")
+		}
+		revisions := d.Revisions[rec.Revision.ChirpId]
+		if rec.Revision.Number > len(revisions) {
+			d.Revisions[rec.Revision.ChirpId] = append(revisions, *rec.Revision)
+		}
+	case opSaveUser:
+		if rec.User == nil {
+			return errors.New("log record missing user")
+		}
+		d.Users[rec.User.Id] = *rec.User
+		d.NextUID = max(d.NextUID, rec.User.Id+1)
+	case opSaveHash:
+		d.Hashes[rec.Id] = rec.Hash
+	case opSaveRefreshToken:
+		d.RefreshTokens[rec.Token] = true
+	case opRemoveRefreshToken:
+		delete(d.RefreshTokens, rec.Token)
+	case opSaveRelation:
+		if rec.Relation == nil {
+			return errors.New("log record missing relation")
+		}
+		d.Relations[rec.Relation.key()] = *rec.Relation
+	case opRemoveRelation:
+		if rec.Relation == nil {
+			return errors.New("log record missing relation")
+		}
+		delete(d.Relations, rec.Relation.key())
+	case opSaveMedia:
+		if rec.Media == nil {
+			return errors.New("log record missing media")
+		}
+		d.Media[rec.Media.Id] = *rec.Media
+		d.NextMID = max(d.NextMID, rec.Media.Id+1)
+	case opRemoveMedia:
+		delete(d.Media, rec.Id)
+	case opSaveDraft:
+		if rec.Draft == nil {
+			return errors.New("log record missing draft")
+		}
+		d.Drafts[rec.Draft.Id] = *rec.Draft
+		d.NextDID = max(d.NextDID, rec.Draft.Id+1)
+	case opRemoveDraft:
+		delete(d.Drafts, rec.Id)
+	case opSaveList:
+		if rec.List == nil {
+			return errors.New("log record missing list")
+		}
+		d.Lists[rec.List.Id] = *rec.List
+		d.NextLID = max(d.NextLID, rec.List.Id+1)
+	case opRemoveList:
+		delete(d.Lists, rec.Id)
+	default:
+		return errors.New("unknown log operation " + rec.Op)
+	}
+
+	return nil
+}
+
+// replayLog applies the records in the log at path, written at schema version
+// from, returning how many were applied. A torn record at the end, left by a
+// crash mid-append, is cut off.
+func replayLog(path string, d *Database, from int) (int, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	count := 0
+	offset := int64(0)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Anything without a trailing newline was never fully written
+			break
+		}
+		if err != nil {
+			return count, err
+		}
+
+		dat, err := migrateLogLine(bytes.TrimSpace(line), from)
+		if err != nil {
+			break
+		}
+
+		rec := logRecord{}
+		err = json.Unmarshal(dat, &rec)
+		if err != nil {
+			break
+		}
+		err = d.apply(rec)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+		offset += int64(len(line))
+	}
+
+	return count, f.Truncate(offset)
+}
+
+// migrateLogLine upgrades the records carried by a log line written at from
+func migrateLogLine(line []byte, from int) ([]byte, error) {
+	if from == SchemaVersion {
+		return line, nil
+	}
+
+	raw := map[string]json.RawMessage{}
+	err := json.Unmarshal(line, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	raw["chirp"], err = migrateRecord("chirps", raw["chirp"], from)
+	if err != nil {
+		return nil, err
+	}
+	raw["user"], err = migrateRecord("users", raw["user"], from)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(raw)
+}
+
+// encodeRecords renders records as log lines
+func encodeRecords(recs []logRecord) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for _, rec := range recs {
+		dat, err := json.Marshal(rec)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(dat)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
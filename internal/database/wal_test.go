@@ -0,0 +1,59 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadReplaysLogOverSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	snapshot := `{"nextcid": 2, "chirps": {"1": {"body": "old", "id": 1, "author_id": 1}}}`
+	err := os.WriteFile(path, []byte(snapshot), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := `{"op":"chirp.save","chirp":{"body":"edited","id":1,"author_id":1}}
+{"op":"chirp.save","chirp":{"body":"new","id":2,"author_id":1}}
+{"op":"token.save","token":"abc"}
+{"op":"chirp.remove","id":2}
+{"op":"chirp.save","chirp":{"body":"torn`
+	err = os.WriteFile(path+".log", []byte(log), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := InitDB(nil, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chirp, err := db.GetChirp(1)
+	if err != nil || chirp.Body != "edited" {
+		t.Errorf("expected edited chirp, got %v, %v", chirp, err)
+	}
+	if _, err := db.GetChirp(2); err == nil {
+		t.Error("expected removed chirp to stay removed")
+	}
+	if !db.IsValidRefreshToken("abc") {
+		t.Error("expected logged refresh token")
+	}
+
+	// The torn record is dropped and ids continue after the replayed ones
+	next, err := db.CreateChirp(1, "after")
+	if err != nil || next.Id != 3 {
+		t.Errorf("expected chirp id 3, got %d, %v", next.Id, err)
+	}
+
+	err = db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing compacts the log into the snapshot
+	info, err := os.Stat(path + ".log")
+	if err != nil || info.Size() != 0 {
+		t.Errorf("expected empty log after close, got %v, %v", info, err)
+	}
+}
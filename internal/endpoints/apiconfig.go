@@ -2,9 +2,17 @@ package endpoints
 
 import (
 	"github.com/Quorum-Code/chirpy/internal/database"
+	"github.com/Quorum-Code/chirpy/internal/media"
 )
 
 type ApiConfig struct {
 	FileserverHits int
-	Db             database.DB
+	Db             *database.DB
+
+	// Uploaded avatars are written to AvatarDir and served under AvatarURL
+	AvatarDir string
+	AvatarURL string
+
+	// Chirp images, stored by content hash
+	Media *media.Store
 }
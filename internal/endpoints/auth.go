@@ -0,0 +1,32 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+var errNotAccessToken error = errors.New("not an access token")
+
+// accessUserID returns the user id of the caller's access token
+func accessUserID(req *http.Request) (int, error) {
+	auth, err := database.RequestToToken(req)
+	if err != nil {
+		return 0, err
+	}
+	if auth.Claim.Issuer != "chirpy-access" {
+		return 0, errNotAccessToken
+	}
+
+	return strconv.Atoi(auth.Claim.Subject)
+}
+
+// viewerID returns the caller's user id, 0 when the request carries no token
+func viewerID(req *http.Request) (int, error) {
+	if req.Header.Get("Authorization") == "" {
+		return 0, nil
+	}
+	return accessUserID(req)
+}
@@ -0,0 +1,68 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) PostBlockHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, cfg.Db.Block)
+}
+
+func (cfg *ApiConfig) DeleteBlockHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, cfg.Db.Unblock)
+}
+
+func (cfg *ApiConfig) PostMuteHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, cfg.Db.Mute)
+}
+
+func (cfg *ApiConfig) DeleteMuteHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, cfg.Db.Unmute)
+}
+
+func (cfg *ApiConfig) GetBlocksHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.writeOwnUserPage(resp, req, cfg.Db.GetBlocks)
+}
+
+func (cfg *ApiConfig) GetMutesHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.writeOwnUserPage(resp, req, cfg.Db.GetMutes)
+}
+
+// writeOwnUserPage responds with a page of the users related to the caller,
+// lists only the caller may see
+func (cfg *ApiConfig) writeOwnUserPage(resp http.ResponseWriter, req *http.Request, get func(int, database.ChirpQuery) (database.UserPage, error)) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	page, err := get(uid, q)
+	if err != nil {
+		resp.WriteHeader(followErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(page.Users)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, page.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
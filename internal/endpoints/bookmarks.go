@@ -0,0 +1,50 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (cfg *ApiConfig) PostBookmarkHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setChirpRelation(resp, req, cfg.Db.Bookmark)
+}
+
+func (cfg *ApiConfig) DeleteBookmarkHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setChirpRelation(resp, req, cfg.Db.Unbookmark)
+}
+
+// GetBookmarksHandler pages through the caller's bookmarks, which no one
+// else can read
+func (cfg *ApiConfig) GetBookmarksHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	page, err := cfg.Db.GetBookmarks(uid, q)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(cfg.Db.ViewChirps(page.Chirps, uid))
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, page.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Quorum-Code/chirpy/internal/database"
 )
@@ -72,12 +73,21 @@ func (cfg *ApiConfig) GetChirpByID(resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
 	// Find chirp
-	chirp, err := cfg.Db.GetChirp(cid)
+	chirp, err := cfg.Db.GetVisibleChirp(cid, viewer)
 	if err != nil {
 		if errors.Is(err, database.ErrChirpNotFound) {
 			resp.WriteHeader(http.StatusNotFound)
 			return
+		} else if errors.Is(err, database.ErrChirpDeleted) {
+			resp.WriteHeader(http.StatusGone)
+			return
 		} else {
 			resp.WriteHeader(http.StatusBadRequest)
 			return
@@ -106,13 +116,14 @@ func (cfg *ApiConfig) PutChirp(resp http.ResponseWriter, req *http.Request) {
 	// Update chirp
 	err = cfg.Db.UserPutChirp(req, cid)
 	if err != nil {
+		// This route has always answered 401 to anyone but the author
+		status := editErrorStatus(err)
 		if errors.Is(err, database.ErrNotAuthorized) {
-			resp.WriteHeader(http.StatusUnauthorized)
-			return
-		} else {
-			resp.WriteHeader(http.StatusInternalServerError)
-			return
+			status = http.StatusUnauthorized
 		}
+		resp.WriteHeader(status)
+		resp.Write([]byte(err.Error()))
+		return
 	}
 
 	// Return 201
@@ -130,6 +141,11 @@ func (cfg *ApiConfig) DeleteChirp(resp http.ResponseWriter, req *http.Request) {
 
 	// Try to delete chirp
 	err = cfg.Db.UserDeleteChirp(req, cid)
+	if errors.Is(err, database.ErrChirpDeleted) {
+		resp.WriteHeader(http.StatusGone)
+		resp.Write([]byte(err.Error()))
+		return
+	}
 	if err != nil {
 		resp.WriteHeader(http.StatusBadRequest)
 		resp.Write([]byte(err.Error()))
@@ -160,7 +176,7 @@ func (cfg *ApiConfig) DeleteChirpsHandler(resp http.ResponseWriter, req *http.Re
 
 	chirp, err := cfg.Db.GetChirp(cid)
 	if err != nil {
-		resp.WriteHeader(500)
+		resp.WriteHeader(chirpErrorStatus(err))
 		resp.Write([]byte(err.Error()))
 		return
 	}
@@ -173,7 +189,12 @@ func (cfg *ApiConfig) DeleteChirpsHandler(resp http.ResponseWriter, req *http.Re
 	}
 
 	if uid == chirp.AuthorId {
-		cfg.Db.DeleteChirp(chirp.Id)
+		err = cfg.Db.DeleteChirp(chirp.Id)
+		if err != nil {
+			resp.WriteHeader(500)
+			resp.Write([]byte(err.Error()))
+			return
+		}
 		resp.WriteHeader(200)
 		resp.Write([]byte("chirp deleted"))
 		return
@@ -185,7 +206,22 @@ func (cfg *ApiConfig) DeleteChirpsHandler(resp http.ResponseWriter, req *http.Re
 
 func (cfg *ApiConfig) PostChirpsHandler(resp http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Body string `json:"chirpBody"`
+		Body      string `json:"chirpBody"`
+		InReplyTo int    `json:"in_reply_to"`
+		QuoteOf   int    `json:"quote_of"`
+		Media     []struct {
+			Id  int    `json:"id"`
+			Alt string `json:"alt"`
+		} `json:"media"`
+		Poll *struct {
+			Options []string `json:"options"`
+
+			// Seconds until the poll closes
+			ExpiresIn int `json:"expires_in"`
+		} `json:"poll"`
+
+		// public, followers or direct, public when left out
+		Visibility string `json:"visibility"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
@@ -222,15 +258,36 @@ func (cfg *ApiConfig) PostChirpsHandler(resp http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	chirp, err := cfg.Db.CreateChirp(id, p.Body)
+	media := make([]database.AttachmentParams, 0, len(p.Media))
+	for _, m := range p.Media {
+		media = append(media, database.AttachmentParams{Id: m.Id, Alt: m.Alt})
+	}
+
+	var poll *database.Poll
+	if p.Poll != nil {
+		poll = &database.Poll{
+			Options:   p.Poll.Options,
+			ExpiresAt: time.Now().Add(time.Duration(p.Poll.ExpiresIn) * time.Second),
+		}
+	}
+
+	chirp, err := cfg.Db.CreateChirpWith(id, database.ChirpParams{
+		Body:      p.Body,
+		InReplyTo: p.InReplyTo,
+		QuoteOf:   p.QuoteOf,
+		Media:     media,
+		Poll:      poll,
+
+		Visibility: p.Visibility,
+	})
 	if err != nil {
-		resp.WriteHeader(500)
+		resp.WriteHeader(createErrorStatus(err))
 		resp.Write([]byte(err.Error()))
 		return
 	}
 
 	resp.WriteHeader(201)
-	dat, err := json.Marshal(chirp)
+	dat, err := json.Marshal(cfg.Db.ViewChirp(chirp, id))
 	if err != nil {
 		resp.WriteHeader(500)
 		resp.Write([]byte(err.Error()))
@@ -241,43 +298,67 @@ func (cfg *ApiConfig) PostChirpsHandler(resp http.ResponseWriter, req *http.Requ
 }
 
 func (cfg *ApiConfig) GetChirpsHandler(resp http.ResponseWriter, req *http.Request) {
-	sid := req.URL.Query().Get("author_id")
-	id, err := strconv.Atoi(sid)
-	var chirps []database.Chirp
-
+	q, err := parseChirpQuery(req)
 	if err != nil {
-		// no id
-		chirps, err = cfg.Db.GetChirps()
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	// Authors are given by id or handle
+	sid := req.URL.Query().Get("author_id")
+	if sid != "" {
+		q.AuthorID, err = strconv.Atoi(sid)
 		if err != nil {
-			resp.WriteHeader(400)
-			resp.Write([]byte(err.Error()))
-			return
+			author, err := cfg.Db.ResolveUser(sid)
+			if err != nil {
+				resp.WriteHeader(404)
+				resp.Write([]byte(err.Error()))
+				return
+			}
+			q.AuthorID = author.Id
 		}
-	} else {
-		// user id
-		chirps, err = cfg.Db.GetChirpsByUserID(id)
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	q.ViewerID = viewer
+	page, err := cfg.Db.GetChirpsPage(q)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	// An author's pinned chirps lead their first page
+	views := []database.ChirpView{}
+	if q.AuthorID != 0 && q.After == 0 {
+		pinned, err := cfg.Db.GetPinned(q.AuthorID, viewer)
 		if err != nil {
-			resp.WriteHeader(400)
+			resp.WriteHeader(500)
 			resp.Write([]byte(err.Error()))
 			return
 		}
-	}
-
-	sort := req.URL.Query().Get("sort")
-	if sort == "desc" {
-		// reverse chirps slice
-		for i, j := 0, len(chirps)-1; i < j; i, j = i+1, j-1 {
-			chirps[i], chirps[j] = chirps[j], chirps[i] //reverse the slice
+		for _, view := range cfg.Db.ViewChirps(pinned, viewer) {
+			view.Pinned = true
+			views = append(views, view)
 		}
 	}
+	views = append(views, cfg.Db.ViewChirps(page.Chirps, viewer)...)
 
-	dat, err := json.Marshal(chirps)
+	dat, err := json.Marshal(views)
 	if err != nil {
 		resp.WriteHeader(500)
 		resp.Write([]byte(err.Error()))
 		return
 	}
 
+	setNextLink(resp, req, page.Next)
 	resp.WriteHeader(200)
 	resp.Write(dat)
 }
@@ -290,14 +371,21 @@ func (cfg *ApiConfig) GetChirpByIDHandler(resp http.ResponseWriter, req *http.Re
 		return
 	}
 
-	chirp, err := cfg.Db.GetChirp(id)
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	chirp, err := cfg.Db.GetVisibleChirp(id, viewer)
 	if err != nil {
-		resp.WriteHeader(404)
+		resp.WriteHeader(chirpErrorStatus(err))
 		resp.Write([]byte(err.Error()))
 		return
 	}
 
-	dat, err := json.Marshal(chirp)
+	dat, err := json.Marshal(cfg.Db.ViewChirp(chirp, viewer))
 	if err != nil {
 		resp.WriteHeader(400)
 		resp.Write([]byte(err.Error()))
@@ -308,6 +396,43 @@ func (cfg *ApiConfig) GetChirpByIDHandler(resp http.ResponseWriter, req *http.Re
 	resp.Write(dat)
 }
 
+// createErrorStatus maps chirp creation errors to response codes
+func createErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrChirpTooLong),
+		errors.Is(err, database.ErrReplyTargetNotFound),
+		errors.Is(err, database.ErrQuoteTargetNotFound),
+		errors.Is(err, database.ErrMediaNotFound),
+		errors.Is(err, database.ErrTooManyMedia),
+		errors.Is(err, database.ErrDuplicateMedia),
+		errors.Is(err, database.ErrAltTooLong),
+		errors.Is(err, database.ErrBadPoll),
+		errors.Is(err, database.ErrBadPollExpiry),
+		errors.Is(err, database.ErrBadVisibility),
+		errors.Is(err, database.ErrNoRecipients):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrRateLimited):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// chirpErrorStatus maps chirp lookup errors to response codes, deleted
+// chirps are gone rather than missing
+func chirpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrChirpDeleted):
+		return http.StatusGone
+	case errors.Is(err, database.ErrChirpNotFound), errors.Is(err, database.ErrNotPublic):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // func ValidateChirpHandler(resp http.ResponseWriter, req *http.Request) {
 // 	type parameters struct {
 // 		Body string `json:"body"`
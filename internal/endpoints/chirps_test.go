@@ -0,0 +1,51 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func TestPutChirpErrors(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	db := database.InitCleanDB()
+	cfg := &ApiConfig{Db: db}
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /chirps/{chirpID}", cfg.PutChirp)
+
+	alice, _ := db.CreateUserWith(database.UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	bob, _ := db.CreateUserWith(database.UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+	chirp, _ := db.CreateChirp(alice.Id, "hello")
+	db.Block(bob.Id, alice.Id)
+	access, err := db.OAuth2Password("a@x.y", "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(chirpID int, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/chirps/"+strconv.Itoa(chirpID), strings.NewReader(`{"chirpBody": "`+body+`"}`))
+		req.Header.Set("Authorization", "Bearer "+access.AccessToken)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := put(chirp.Id, "hi @bob")
+	if rec.Code != http.StatusForbidden || rec.Body.Len() == 0 {
+		t.Errorf("expected 403 with a message mentioning a blocker, got %d %q", rec.Code, rec.Body)
+	}
+
+	rec = put(chirp.Id+1, "hi")
+	if rec.Code != http.StatusNotFound || rec.Body.Len() == 0 {
+		t.Errorf("expected 404 with a message for a missing chirp, got %d %q", rec.Code, rec.Body)
+	}
+
+	rec = put(chirp.Id, "hi")
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected 201, got %d %q", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,144 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) GetDraftsHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	drafts, err := cfg.Db.GetDrafts(uid)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	writeJSON(resp, 200, drafts)
+}
+
+// PostDraftHandler saves a draft, scheduled for publishing when it has a
+// publish_at time
+func (cfg *ApiConfig) PostDraftHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := database.DraftParams{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("unparseable body"))
+		return
+	}
+
+	draft, err := cfg.Db.CreateDraft(uid, params)
+	if err != nil {
+		resp.WriteHeader(draftErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.Header().Set("Location", "/api/drafts/"+strconv.Itoa(draft.Id))
+	writeJSON(resp, 201, draft)
+}
+
+// PutDraftHandler replaces a draft, clearing publish_at unschedules it
+func (cfg *ApiConfig) PutDraftHandler(resp http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("draftID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("draftID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := database.DraftParams{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("unparseable body"))
+		return
+	}
+
+	draft, err := cfg.Db.UpdateDraft(id, uid, params)
+	if err != nil {
+		resp.WriteHeader(draftErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	writeJSON(resp, 200, draft)
+}
+
+// DeleteDraftHandler cancels a draft or scheduled chirp
+func (cfg *ApiConfig) DeleteDraftHandler(resp http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("draftID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("draftID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	err = cfg.Db.DeleteDraft(id, uid)
+	if err != nil {
+		resp.WriteHeader(draftErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func writeJSON(resp http.ResponseWriter, status int, v any) {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(status)
+	resp.Write(dat)
+}
+
+// draftErrorStatus maps draft errors to response codes, drafts are checked
+// like the chirps they publish
+func draftErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrDraftNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrBadPublishAt):
+		return http.StatusBadRequest
+	default:
+		return createErrorStatus(err)
+	}
+}
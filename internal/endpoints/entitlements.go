@@ -0,0 +1,32 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+// GetEntitlementsHandler responds with the plan of the caller and the limits
+// that come with it
+func (cfg *ApiConfig) GetEntitlementsHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	plan, err := cfg.Db.Entitlements(uid)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			resp.WriteHeader(404)
+		} else {
+			resp.WriteHeader(500)
+		}
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	writeJSON(resp, 200, plan)
+}
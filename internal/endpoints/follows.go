@@ -0,0 +1,137 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) PostFollowHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, cfg.Db.Follow)
+}
+
+func (cfg *ApiConfig) DeleteFollowHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(resp, req, cfg.Db.Unfollow)
+}
+
+// setFollow applies a follow, block or mute change from the caller to the
+// user in the path
+func (cfg *ApiConfig) setFollow(resp http.ResponseWriter, req *http.Request, apply func(int, int) error) {
+	userID, err := strconv.Atoi(req.PathValue("userID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("userID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	err = apply(uid, userID)
+	if err != nil {
+		resp.WriteHeader(followErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func (cfg *ApiConfig) GetFollowersHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.writeUserPage(resp, req, cfg.Db.GetFollowers)
+}
+
+func (cfg *ApiConfig) GetFollowingHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.writeUserPage(resp, req, cfg.Db.GetFollowing)
+}
+
+// writeUserPage responds with a page of the users related to the user in the
+// path
+func (cfg *ApiConfig) writeUserPage(resp http.ResponseWriter, req *http.Request, get func(int, database.ChirpQuery) (database.UserPage, error)) {
+	userID, err := strconv.Atoi(req.PathValue("userID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("userID must be int"))
+		return
+	}
+
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	page, err := get(userID, q)
+	if err != nil {
+		resp.WriteHeader(followErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(page.Users)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, page.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+func (cfg *ApiConfig) GetTimelineHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	page, err := cfg.Db.GetTimeline(uid, q)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(cfg.Db.ViewChirps(page.Chirps, uid))
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, page.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// followErrorStatus maps follow graph errors to response codes
+func followErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrFollowSelf), errors.Is(err, database.ErrBlockSelf):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrBlocked):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
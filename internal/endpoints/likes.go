@@ -0,0 +1,60 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) PostLikeHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setChirpRelation(resp, req, cfg.Db.Like)
+}
+
+func (cfg *ApiConfig) DeleteLikeHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setChirpRelation(resp, req, cfg.Db.Unlike)
+}
+
+func (cfg *ApiConfig) PostRechirpHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setChirpRelation(resp, req, cfg.Db.Rechirp)
+}
+
+func (cfg *ApiConfig) DeleteRechirpHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setChirpRelation(resp, req, cfg.Db.Unrechirp)
+}
+
+// setChirpRelation applies a like or rechirp change from the caller to the
+// chirp in the path, responding with the updated chirp
+func (cfg *ApiConfig) setChirpRelation(resp http.ResponseWriter, req *http.Request, apply func(int, int) (database.ChirpView, error)) {
+	cid, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("chirpID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	view, err := apply(uid, cid)
+	if err != nil {
+		resp.WriteHeader(chirpErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(view)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
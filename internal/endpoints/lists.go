@@ -0,0 +1,293 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) PostListHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := database.ListParams{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("unparseable body"))
+		return
+	}
+
+	l, err := cfg.Db.CreateList(uid, params)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.Header().Set("Location", "/api/lists/"+strconv.Itoa(l.Id))
+	writeJSON(resp, 201, l)
+}
+
+// GetUserListsHandler lists the lists of the user in the path, private ones
+// only for that user
+func (cfg *ApiConfig) GetUserListsHandler(resp http.ResponseWriter, req *http.Request) {
+	userID, err := strconv.Atoi(req.PathValue("userID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("userID must be int"))
+		return
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	lists, err := cfg.Db.GetLists(userID, viewer)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	writeJSON(resp, 200, lists)
+}
+
+func (cfg *ApiConfig) GetListHandler(resp http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("listID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("listID must be int"))
+		return
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	l, err := cfg.Db.GetList(id, viewer)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	writeJSON(resp, 200, l)
+}
+
+// PutListHandler renames a list or changes its description or privacy,
+// only its owner can
+func (cfg *ApiConfig) PutListHandler(resp http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("listID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("listID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := database.ListParams{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("unparseable body"))
+		return
+	}
+
+	l, err := cfg.Db.UpdateList(id, uid, params)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	writeJSON(resp, 200, l)
+}
+
+func (cfg *ApiConfig) DeleteListHandler(resp http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("listID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("listID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	err = cfg.Db.DeleteList(id, uid)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func (cfg *ApiConfig) PostListMemberHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setListMember(resp, req, cfg.Db.AddListMember)
+}
+
+func (cfg *ApiConfig) DeleteListMemberHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setListMember(resp, req, cfg.Db.RemoveListMember)
+}
+
+// setListMember applies a membership change by the caller to the list and
+// user in the path
+func (cfg *ApiConfig) setListMember(resp http.ResponseWriter, req *http.Request, apply func(int, int, int) error) {
+	id, err := strconv.Atoi(req.PathValue("listID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("listID must be int"))
+		return
+	}
+
+	userID, err := strconv.Atoi(req.PathValue("userID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("userID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	err = apply(id, uid, userID)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+func (cfg *ApiConfig) GetListMembersHandler(resp http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("listID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("listID must be int"))
+		return
+	}
+
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	page, err := cfg.Db.GetListMembers(id, viewer, q)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(page.Users)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, page.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// GetListTimelineHandler pages through the chirps of a list's members
+func (cfg *ApiConfig) GetListTimelineHandler(resp http.ResponseWriter, req *http.Request) {
+	id, err := strconv.Atoi(req.PathValue("listID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("listID must be int"))
+		return
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	page, err := cfg.Db.GetListTimeline(id, viewer, q)
+	if err != nil {
+		resp.WriteHeader(listErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(cfg.Db.ViewChirps(page.Chirps, viewer))
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, page.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// listErrorStatus maps list errors to response codes, other users' private
+// lists are missing rather than forbidden
+func listErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrListNotFound), errors.Is(err, database.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrNotAuthorized), errors.Is(err, database.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrBadListName),
+		errors.Is(err, database.ErrListDescriptionTooLong),
+		errors.Is(err, database.ErrListFull):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -2,12 +2,14 @@ package endpoints
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 
+	"github.com/Quorum-Code/chirpy/internal/database"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -28,16 +30,16 @@ func (cfg *ApiConfig) PostSignupHandler(resp http.ResponseWriter, req *http.Requ
 		return
 	}
 
-	// Check email not used
-	if cfg.Db.IsEmailUsed(email) {
-		resp.WriteHeader(http.StatusBadRequest)
-		resp.Write([]byte("Email already used by another account"))
-		return
-	}
-
+	// Emails are checked for reuse under the database lock
 	_, err := cfg.Db.CreateUser(email, password)
 	if err != nil {
-		resp.WriteHeader(http.StatusInternalServerError)
+		if errors.Is(err, database.ErrEmailTaken) {
+			resp.WriteHeader(http.StatusConflict)
+			resp.Write([]byte(err.Error()))
+		} else {
+			resp.WriteHeader(http.StatusInternalServerError)
+		}
+		return
 	}
 
 	resp.WriteHeader(http.StatusAccepted)
@@ -54,6 +56,7 @@ func (cfg *ApiConfig) PostLoginHandler(resp http.ResponseWriter, req *http.Reque
 		Id           int    `json:"id"`
 		Email        string `json:"email"`
 		IsChirpyRed  bool   `json:"is_chirpy_red"`
+		Plan         string `json:"plan"`
 		Token        string `json:"token"`
 		RefreshToken string `json:"refresh_token"`
 	}
@@ -101,9 +104,14 @@ func (cfg *ApiConfig) PostLoginHandler(resp http.ResponseWriter, req *http.Reque
 		resp.WriteHeader(402)
 		return
 	}
-	cfg.Db.AddRefreshToken(trs)
+	err = cfg.Db.AddRefreshToken(trs)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
 
-	d := details{Id: user.Id, Email: user.Email, IsChirpyRed: user.IsChirpyRed, Token: ts, RefreshToken: trs}
+	d := details{Id: user.Id, Email: user.Email, IsChirpyRed: user.IsChirpyRed, Plan: user.Plan, Token: ts, RefreshToken: trs}
 
 	dat, err := json.Marshal(d)
 	if err != nil {
@@ -0,0 +1,141 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+	"github.com/Quorum-Code/chirpy/internal/media"
+)
+
+// PostMediaHandler takes an image in the file field of a multipart form,
+// to be attached to a chirp by id
+func (cfg *ApiConfig) PostMediaHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	req.Body = http.MaxBytesReader(resp, req.Body, media.MaxUploadSize+1<<10)
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("media must be an image of at most 8 MiB in the file form field"))
+		return
+	}
+	defer file.Close()
+
+	dat, err := io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	img, err := media.Process(dat)
+	if err != nil {
+		resp.WriteHeader(mediaErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	// Keep garbage collection from deleting the files before they're recorded
+	cfg.Media.RLock()
+	defer cfg.Media.RUnlock()
+
+	hash, err := cfg.Media.Save(img)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	url, thumbURL := cfg.Media.URLs(hash, img.ContentType, img.ThumbContentType)
+	m, err := cfg.Db.CreateMedia(database.Media{
+		OwnerId:     uid,
+		Hash:        hash,
+		ContentType: img.ContentType,
+		Size:        len(img.Data),
+		Width:       img.Width,
+		Height:      img.Height,
+		URL:         url,
+
+		ThumbnailContentType: img.ThumbContentType,
+		ThumbnailWidth:       img.ThumbWidth,
+		ThumbnailHeight:      img.ThumbHeight,
+		ThumbnailURL:         thumbURL,
+	})
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err = json.Marshal(m)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.Header().Set("Location", "/api/media/"+strconv.Itoa(m.Id))
+	resp.WriteHeader(201)
+	resp.Write(dat)
+}
+
+// GetMediaHandler shows an upload to its uploader. Others see images only
+// through the chirps they can read, so uploads of private chirps and drafts
+// stay hidden.
+func (cfg *ApiConfig) GetMediaHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	id, err := strconv.Atoi(req.PathValue("mediaID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	// Other users' uploads look the same as missing ones
+	m, err := cfg.Db.GetMedia(id)
+	if err == nil && m.OwnerId != uid {
+		err = database.ErrMediaNotFound
+	}
+	if err != nil {
+		resp.WriteHeader(404)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(m)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// mediaErrorStatus maps upload errors to response codes
+func mediaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, media.ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, media.ErrUnsupportedType):
+		return http.StatusUnsupportedMediaType
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,46 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func TestGetMediaOnlyForUploader(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	db := database.InitCleanDB()
+	cfg := &ApiConfig{Db: db}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/media/{mediaID}", cfg.GetMediaHandler)
+
+	alice, _ := db.CreateUserWith(database.UserParams{Email: "a@x.y", Password: "pw", Handle: "alice"})
+	db.CreateUserWith(database.UserParams{Email: "b@x.y", Password: "pw", Handle: "bob"})
+	m, _ := db.CreateMedia(database.Media{OwnerId: alice.Id, Hash: "aa"})
+
+	get := func(email string) int {
+		req := httptest.NewRequest("GET", "/api/media/"+strconv.Itoa(m.Id), nil)
+		if email != "" {
+			access, err := db.OAuth2Password(email, "pw")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+access.AccessToken)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := get("a@x.y"); code != http.StatusOK {
+		t.Errorf("expected the uploader to get 200, got %d", code)
+	}
+	if code := get("b@x.y"); code != http.StatusNotFound {
+		t.Errorf("expected another user to get 404, got %d", code)
+	}
+	if code := get(""); code != http.StatusUnauthorized {
+		t.Errorf("expected an anonymous caller to get 401, got %d", code)
+	}
+}
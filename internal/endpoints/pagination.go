@@ -0,0 +1,118 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+// parseChirpQuery reads the paging parameters shared by chirp lists:
+// limit, cursor, since_id, max_id, created_after, created_before and
+// sort=desc. Chirps are ordered by id, which is also creation order.
+func parseChirpQuery(req *http.Request) (database.ChirpQuery, error) {
+	query := req.URL.Query()
+	q := database.ChirpQuery{Desc: query.Get("sort") == "desc"}
+
+	ints := map[string]*int{
+		"limit":    &q.Limit,
+		"since_id": &q.SinceID,
+		"max_id":   &q.MaxID,
+	}
+	for name, dst := range ints {
+		s := query.Get(name)
+		if s == "" {
+			continue
+		}
+
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return q, fmt.Errorf("%s must be a positive int", name)
+		}
+		*dst = v
+	}
+
+	times := map[string]*time.Time{
+		"created_after":  &q.CreatedAfter,
+		"created_before": &q.CreatedBefore,
+	}
+	for name, dst := range times {
+		s := query.Get(name)
+		if s == "" {
+			continue
+		}
+
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return q, fmt.Errorf("%s must be an RFC 3339 time", name)
+		}
+		*dst = v
+	}
+
+	cursor := query.Get("cursor")
+	if cursor != "" {
+		after, err := database.DecodeCursor(cursor)
+		if err != nil {
+			return q, errors.New("cursor is not valid")
+		}
+		q.After = after
+	}
+
+	return q, nil
+}
+
+// parseSearchQuery reads the paging parameters of search results: limit and
+// an offset cursor. Results are ranked rather than ordered by id, so the
+// bounds and sort of chirp lists are rejected.
+func parseSearchQuery(req *http.Request) (database.ChirpQuery, error) {
+	query := req.URL.Query()
+	q := database.ChirpQuery{}
+
+	for _, name := range []string{"since_id", "max_id", "created_after", "created_before", "sort"} {
+		if query.Has(name) {
+			return q, fmt.Errorf("%s is not supported by search", name)
+		}
+	}
+
+	if s := query.Get("limit"); s != "" {
+		v, err := strconv.Atoi(s)
+		if err != nil || v < 0 {
+			return q, errors.New("limit must be a positive int")
+		}
+		q.Limit = v
+	}
+
+	cursor := query.Get("cursor")
+	if cursor != "" {
+		offset, err := database.DecodeOffsetCursor(cursor)
+		if err != nil {
+			return q, errors.New("cursor is not valid")
+		}
+		q.After = offset
+	}
+
+	return q, nil
+}
+
+// setNextLink points the Link header at the page following next, keeping
+// the other query parameters
+func setNextLink(resp http.ResponseWriter, req *http.Request, next int) {
+	if next == 0 {
+		return
+	}
+	setCursorLink(resp, req, database.EncodeCursor(next))
+}
+
+// setCursorLink points the Link header at the page cursor starts, keeping
+// the other query parameters
+func setCursorLink(resp http.ResponseWriter, req *http.Request, cursor string) {
+	query := req.URL.Query()
+	query.Set("cursor", cursor)
+
+	u := *req.URL
+	u.RawQuery = query.Encode()
+	resp.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", u.RequestURI()))
+}
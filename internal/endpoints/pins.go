@@ -0,0 +1,55 @@
+package endpoints
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) PostPinHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setPin(resp, req, cfg.Db.Pin)
+}
+
+func (cfg *ApiConfig) DeletePinHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.setPin(resp, req, cfg.Db.Unpin)
+}
+
+// setPin pins or unpins the chirp in the path on the caller's profile
+func (cfg *ApiConfig) setPin(resp http.ResponseWriter, req *http.Request, apply func(int, int) error) {
+	cid, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("chirpID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	err = apply(uid, cid)
+	if err != nil {
+		resp.WriteHeader(pinErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(204)
+}
+
+// pinErrorStatus maps pin errors to response codes
+func pinErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrNotAuthorized):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrPinLimit):
+		return http.StatusConflict
+	default:
+		return chirpErrorStatus(err)
+	}
+}
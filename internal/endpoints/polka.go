@@ -35,8 +35,10 @@ func (cfg *ApiConfig) PostPolkaWebhook(resp http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	if b.Event == "user.upgraded" {
-		err := cfg.Db.UpgradeUser(b.Data.UserID)
+	// Events move users between plans, others are acknowledged and ignored
+	plan, ok := cfg.Db.PolkaPlan(b.Event)
+	if ok {
+		err := cfg.Db.SetUserPlan(b.Data.UserID, plan)
 
 		if err != nil {
 			resp.WriteHeader(404)
@@ -0,0 +1,71 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+// PostVoteHandler votes for an option, by index, of the poll on a chirp
+func (cfg *ApiConfig) PostVoteHandler(resp http.ResponseWriter, req *http.Request) {
+	cid, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("chirpID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	type parameters struct {
+		Option *int `json:"option"`
+	}
+	decoder := json.NewDecoder(req.Body)
+	p := parameters{}
+	err = decoder.Decode(&p)
+	if err != nil || p.Option == nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("option must be the index of a poll option"))
+		return
+	}
+
+	view, err := cfg.Db.Vote(uid, cid, *p.Option)
+	if err != nil {
+		resp.WriteHeader(voteErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(view)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// voteErrorStatus maps voting errors to response codes
+func voteErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrNoPoll):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrBadPollOption):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrAlreadyVoted),
+		errors.Is(err, database.ErrPollClosed):
+		return http.StatusConflict
+	default:
+		return chirpErrorStatus(err)
+	}
+}
@@ -0,0 +1,151 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+	"github.com/Quorum-Code/chirpy/internal/media"
+)
+
+// Largest avatar upload accepted
+const MaxAvatarSize = 2 << 20
+
+func (cfg *ApiConfig) GetUserHandler(resp http.ResponseWriter, req *http.Request) {
+	user, err := cfg.Db.ResolveUser(req.PathValue("handle"))
+	if err != nil {
+		resp.WriteHeader(404)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(user.Public())
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+func (cfg *ApiConfig) PutProfileHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	update := database.ProfileUpdate{}
+	err = decoder.Decode(&update)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("unparseable body"))
+		return
+	}
+
+	user, err := cfg.Db.UpdateProfile(uid, update)
+	if err != nil {
+		resp.WriteHeader(profileErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(user.Public())
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// PostAvatarHandler takes an image in the avatar field of a multipart form
+func (cfg *ApiConfig) PostAvatarHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	req.Body = http.MaxBytesReader(resp, req.Body, MaxAvatarSize+1<<10)
+	file, _, err := req.FormFile("avatar")
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("avatar must be an image of at most 2 MiB in the avatar form field"))
+		return
+	}
+	defer file.Close()
+
+	dat, err := io.ReadAll(io.LimitReader(file, MaxAvatarSize+1))
+	if err != nil || len(dat) > MaxAvatarSize {
+		resp.WriteHeader(400)
+		resp.Write([]byte("avatar must be at most 2 MiB"))
+		return
+	}
+
+	// Decode and encode again like other uploads, dropping EXIF and GPS
+	img, err := media.Process(dat)
+	if err != nil {
+		resp.WriteHeader(mediaErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	// Name by content so a new avatar gets a new URL past caches
+	name := fmt.Sprintf("%d-%s%s", uid, img.Hash()[:16], media.Ext(img.ContentType))
+
+	err = os.MkdirAll(cfg.AvatarDir, 0755)
+	if err == nil {
+		err = media.WriteFile(filepath.Join(cfg.AvatarDir, name), img.Data)
+	}
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	old, err := cfg.Db.SetAvatar(uid, cfg.AvatarURL+name)
+	if err != nil {
+		resp.WriteHeader(profileErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	// Remove the replaced upload, keeping it if it is the same image
+	oldName, ok := strings.CutPrefix(old, cfg.AvatarURL)
+	if ok && oldName != name && filepath.Base(oldName) == oldName {
+		os.Remove(filepath.Join(cfg.AvatarDir, oldName))
+	}
+
+	resp.Header().Set("Location", cfg.AvatarURL+name)
+	resp.WriteHeader(201)
+}
+
+// profileErrorStatus maps account and profile errors to response codes
+func profileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrHandleTaken), errors.Is(err, database.ErrEmailTaken):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrBadHandle),
+		errors.Is(err, database.ErrDisplayNameTooLong),
+		errors.Is(err, database.ErrBioTooLong):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
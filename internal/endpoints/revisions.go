@@ -0,0 +1,108 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) GetRevisionsHandler(resp http.ResponseWriter, req *http.Request) {
+	cid, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("chirpID must be int"))
+		return
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	// History is hidden along with the chirp
+	_, err = cfg.Db.GetVisibleChirp(cid, viewer)
+	if err != nil {
+		resp.WriteHeader(chirpErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	revisions, err := cfg.Db.GetRevisions(cid)
+	if err != nil {
+		resp.WriteHeader(chirpErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(revisions)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+func (cfg *ApiConfig) PostRestoreRevisionHandler(resp http.ResponseWriter, req *http.Request) {
+	cid, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("chirpID must be int"))
+		return
+	}
+
+	number, err := strconv.Atoi(req.PathValue("revision"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("revision must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	chirp, err := cfg.Db.RestoreRevision(cid, number, uid)
+	if err != nil {
+		resp.WriteHeader(editErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(chirp)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// editErrorStatus maps chirp edit errors to response codes
+func editErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrChirpDeleted):
+		return http.StatusGone
+	case errors.Is(err, database.ErrChirpNotFound), errors.Is(err, database.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrNotAuthorized), errors.Is(err, database.ErrEditWindowClosed),
+		errors.Is(err, database.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrChirpTooLong):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -12,7 +12,12 @@ func (cfg *ApiConfig) PostRevoke(resp http.ResponseWriter, req *http.Request) {
 		tk = split[1]
 	}
 
-	cfg.Db.RevokeRefreshToken(tk)
+	err := cfg.Db.RevokeRefreshToken(tk)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
 
 	resp.WriteHeader(200)
 }
@@ -0,0 +1,50 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) GetSearchChirpsHandler(resp http.ResponseWriter, req *http.Request) {
+	q, err := parseSearchQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	q.ViewerID = viewer
+	page, err := cfg.Db.SearchChirps(req.URL.Query().Get("q"), q)
+	if err != nil {
+		if errors.Is(err, database.ErrEmptySearch) || errors.Is(err, database.ErrBadSearchFilter) {
+			resp.WriteHeader(400)
+		} else {
+			resp.WriteHeader(500)
+		}
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(cfg.Db.ViewChirps(page.Chirps, viewer))
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	if page.Next != 0 {
+		setCursorLink(resp, req, database.EncodeOffsetCursor(page.Next))
+	}
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
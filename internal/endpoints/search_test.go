@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func TestSearchCursors(t *testing.T) {
+	db := database.InitCleanDB()
+	cfg := &ApiConfig{Db: db}
+	db.CreateChirp(1, "hello one")
+	db.CreateChirp(1, "hello two")
+
+	search := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		cfg.GetSearchChirpsHandler(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	rec := search("/api/search/chirps?q=hello&limit=1")
+	if rec.Code != http.StatusOK || rec.Header().Get("Link") == "" {
+		t.Fatalf("expected a first page with a next link, got %d %q", rec.Code, rec.Header().Get("Link"))
+	}
+	rec = search("/api/search/chirps?q=hello&limit=1&cursor=" + database.EncodeOffsetCursor(1))
+	if rec.Code != http.StatusOK || rec.Header().Get("Link") != "" {
+		t.Errorf("expected a last page without a next link, got %d %q", rec.Code, rec.Header().Get("Link"))
+	}
+
+	// Timeline cursors are ids, not offsets
+	rec = search("/api/search/chirps?q=hello&cursor=" + database.EncodeCursor(1))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a timeline cursor, got %d", rec.Code)
+	}
+
+	rec = search("/api/search/chirps?q=hello&since_id=1")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for since_id, got %d", rec.Code)
+	}
+}
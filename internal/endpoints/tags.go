@@ -0,0 +1,111 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) GetTagChirpsHandler(resp http.ResponseWriter, req *http.Request) {
+	cfg.writeChirpPage(resp, req, func(q database.ChirpQuery) (database.ChirpPage, error) {
+		return cfg.Db.GetTagChirps(req.PathValue("tag"), q)
+	})
+}
+
+// GetMentionsHandler lists the chirps mentioning the user in the path, given
+// by id or handle
+func (cfg *ApiConfig) GetMentionsHandler(resp http.ResponseWriter, req *http.Request) {
+	user, err := cfg.Db.ResolveUser(req.PathValue("userID"))
+	if err != nil {
+		resp.WriteHeader(404)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	cfg.writeChirpPage(resp, req, func(q database.ChirpQuery) (database.ChirpPage, error) {
+		return cfg.Db.GetMentions(user.Id, q)
+	})
+}
+
+func (cfg *ApiConfig) GetTrendingTagsHandler(resp http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	window := time.Duration(0)
+	if s := query.Get("window"); s != "" {
+		var err error
+		window, err = time.ParseDuration(s)
+		if err != nil || window <= 0 {
+			resp.WriteHeader(400)
+			resp.Write([]byte("window must be a positive duration such as 24h"))
+			return
+		}
+	}
+
+	limit := 0
+	if s := query.Get("limit"); s != "" {
+		var err error
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit < 0 {
+			resp.WriteHeader(400)
+			resp.Write([]byte("limit must be a positive int"))
+			return
+		}
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(cfg.Db.TrendingTags(window, limit, viewer))
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// writeChirpPage responds with the page of chirps get returns for the paging
+// parameters of the request, as seen by the caller
+func (cfg *ApiConfig) writeChirpPage(resp http.ResponseWriter, req *http.Request, get func(database.ChirpQuery) (database.ChirpPage, error)) {
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	q.ViewerID = viewer
+	page, err := get(q)
+	if err != nil {
+		resp.WriteHeader(chirpErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(cfg.Db.ViewChirps(page.Chirps, viewer))
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, page.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
@@ -0,0 +1,59 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+func (cfg *ApiConfig) GetThreadHandler(resp http.ResponseWriter, req *http.Request) {
+	cid, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("chirpID must be int"))
+		return
+	}
+
+	q, err := parseChirpQuery(req)
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	depth := 0
+	s := req.URL.Query().Get("depth")
+	if s != "" {
+		depth, err = strconv.Atoi(s)
+		if err != nil || depth < 1 {
+			resp.WriteHeader(400)
+			resp.Write([]byte("depth must be a positive int"))
+			return
+		}
+	}
+
+	viewer, err := viewerID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	thread, err := cfg.Db.GetThread(cid, q, depth, viewer)
+	if err != nil {
+		resp.WriteHeader(chirpErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(thread)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	setNextLink(resp, req, thread.Next)
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
@@ -0,0 +1,85 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
+func (cfg *ApiConfig) PostRestoreChirpHandler(resp http.ResponseWriter, req *http.Request) {
+	cid, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		resp.WriteHeader(400)
+		resp.Write([]byte("chirpID must be int"))
+		return
+	}
+
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	chirp, err := cfg.Db.RestoreChirp(cid, uid)
+	if err != nil {
+		resp.WriteHeader(restoreErrorStatus(err))
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(chirp)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+func (cfg *ApiConfig) GetTrashHandler(resp http.ResponseWriter, req *http.Request) {
+	uid, err := accessUserID(req)
+	if err != nil {
+		resp.WriteHeader(401)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	chirps, err := cfg.Db.GetTrash(uid)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	dat, err := json.Marshal(chirps)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.WriteHeader(200)
+	resp.Write(dat)
+}
+
+// restoreErrorStatus maps chirp restore errors to response codes
+func restoreErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrChirpNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrNotAuthorized):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrChirpNotDeleted):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrTrashExpired):
+		return http.StatusGone
+	default:
+		return http.StatusInternalServerError
+	}
+}
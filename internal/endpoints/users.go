@@ -2,12 +2,14 @@ package endpoints
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/Quorum-Code/chirpy/internal/database"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -18,8 +20,11 @@ type parameters struct {
 
 func (cfg *ApiConfig) PostUserHandler(resp http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Email string `json:"email"`
-		Pass  string `json:"password"`
+		Email       string `json:"email"`
+		Pass        string `json:"password"`
+		Handle      string `json:"handle"`
+		DisplayName string `json:"display_name"`
+		Bio         string `json:"bio"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
@@ -31,14 +36,27 @@ func (cfg *ApiConfig) PostUserHandler(resp http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	user, err := cfg.Db.CreateUser(p.Email, p.Pass)
+	user, err := cfg.Db.CreateUserWith(database.UserParams{
+		Email:       p.Email,
+		Password:    p.Pass,
+		Handle:      p.Handle,
+		DisplayName: p.DisplayName,
+		Bio:         p.Bio,
+	})
 	if err != nil {
-		resp.WriteHeader(500)
-		resp.Write([]byte("something went wrong while creating the user"))
+		status := profileErrorStatus(err)
+		if status == 500 {
+			resp.WriteHeader(500)
+			resp.Write([]byte("something went wrong while creating the user"))
+			return
+		}
+		resp.WriteHeader(status)
+		resp.Write([]byte(err.Error()))
 		return
 	}
 
-	dat, err := json.Marshal(user)
+	// The email stays private, the handle identifies the user
+	dat, err := json.Marshal(user.Public())
 	if err != nil {
 		resp.WriteHeader(500)
 		resp.Write([]byte("something went wrong while decoding the user"))
@@ -109,7 +127,11 @@ func (cfg *ApiConfig) PutUsersHandler(resp http.ResponseWriter, req *http.Reques
 
 	user, err := cfg.Db.UpdateUser(id, p.Email, p.Password)
 	if err != nil {
-		resp.WriteHeader(401)
+		if errors.Is(err, database.ErrEmailTaken) {
+			resp.WriteHeader(409)
+		} else {
+			resp.WriteHeader(401)
+		}
 		resp.Write([]byte(err.Error()))
 		return
 	}
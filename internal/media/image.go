@@ -0,0 +1,206 @@
+package media
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+)
+
+// thumbnail scales img down to fit in a size by size square, averaging the
+// source pixels behind each thumbnail pixel
+func thumbnail(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	thumb := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := b.Min.Y+y*h/th, b.Min.Y+(y+1)*h/th
+		for x := 0; x < tw; x++ {
+			x0, x1 := b.Min.X+x*w/tw, b.Min.X+(x+1)*w/tw
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			thumb.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return thumb
+}
+
+// orient applies an EXIF orientation, 1 to 8, so the image displays upright
+// without it
+func orient(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// Orientations 5 to 8 swap the axes
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return dst
+}
+
+// exifOrientation reads the orientation tag from the EXIF segment of a JPEG,
+// 1 when there is none
+func exifOrientation(dat []byte) int {
+	if len(dat) < 4 || dat[0] != 0xFF || dat[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the segments before the image data
+	for i := 2; i+4 <= len(dat); {
+		if dat[i] != 0xFF {
+			return 1
+		}
+		marker := dat[i+1]
+		length := int(binary.BigEndian.Uint16(dat[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(dat) {
+			return 1
+		}
+
+		segment := dat[i+4 : i+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+
+	return 1
+}
+
+// tiffOrientation finds tag 0x0112 in the first IFD of a TIFF header
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < entries; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+
+	return 1
+}
+
+// gifFrames counts the frames of a GIF and the pixels they decode to, from
+// the image descriptors alone. A malformed stream counts as far as it goes
+// and leaves the decoder to reject it.
+func gifFrames(dat []byte) (int, int) {
+	if len(dat) < 13 {
+		return 0, 0
+	}
+
+	// Skip the header, screen descriptor and global color table
+	i := 13
+	if dat[10]&0x80 != 0 {
+		i += 3 << (dat[10]&0x07 + 1)
+	}
+
+	frames, pixels := 0, 0
+	for i < len(dat) {
+		switch dat[i] {
+		case 0x21:
+			// Extension label, then data sub-blocks
+			i = skipSubBlocks(dat, i+2)
+		case 0x2C:
+			if i+10 > len(dat) {
+				return frames, pixels
+			}
+			width := int(binary.LittleEndian.Uint16(dat[i+5:]))
+			height := int(binary.LittleEndian.Uint16(dat[i+7:]))
+			frames++
+			pixels += width * height
+
+			// Local color table and LZW code size, then image data sub-blocks
+			packed := dat[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&0x07 + 1)
+			}
+			i = skipSubBlocks(dat, i+1)
+		default:
+			// Trailer or garbage
+			return frames, pixels
+		}
+	}
+
+	return frames, pixels
+}
+
+// skipSubBlocks returns the index after the sub-blocks starting at i
+func skipSubBlocks(dat []byte, i int) int {
+	for i < len(dat) && dat[i] != 0 {
+		i += 1 + int(dat[i])
+	}
+	return i + 1
+}
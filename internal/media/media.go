@@ -0,0 +1,270 @@
+// Package media validates uploaded images and stores them by content.
+//
+// Images are decoded and encoded again with the standard image packages,
+// which drops EXIF and any other metadata, after applying the EXIF
+// orientation so photos stay upright.
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// Largest upload accepted
+	MaxUploadSize = 8 << 20
+
+	// Largest decoded image accepted, guarding against decompression bombs.
+	// Animated GIFs count the pixels of every frame.
+	MaxPixels = 40_000_000
+
+	// Most frames in an animated GIF
+	MaxFrames = 500
+
+	// Thumbnails fit in a square this size
+	ThumbSize = 320
+)
+
+var ErrUnsupportedType error = errors.New("media must be a png, jpeg or gif image")
+var ErrTooLarge error = errors.New("media is too large")
+
+// Image is a processed upload, ready to be stored
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+
+	Thumb            []byte
+	ThumbContentType string
+	ThumbWidth       int
+	ThumbHeight      int
+}
+
+// Hash is the content address of the processed image
+func (img Image) Hash() string {
+	sum := sha256.Sum256(img.Data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Process validates an upload, strips its metadata and makes a thumbnail
+func Process(dat []byte) (Image, error) {
+	if len(dat) > MaxUploadSize {
+		return Image{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(dat)
+	if Ext(contentType) == "" {
+		return Image{}, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(dat))
+	if err != nil {
+		return Image{}, ErrUnsupportedType
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	processed := Image{ContentType: contentType}
+	var frame image.Image
+	buf := bytes.Buffer{}
+
+	switch contentType {
+	case "image/gif":
+		// Check the frames before decoding them all
+		frames, pixels := gifFrames(dat)
+		if frames > MaxFrames || pixels > MaxPixels {
+			return Image{}, ErrTooLarge
+		}
+
+		// Keep every frame, dropping comments and application extensions
+		anim, err := gif.DecodeAll(bytes.NewReader(dat))
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		err = gif.EncodeAll(&buf, &gif.GIF{
+			Image:     anim.Image,
+			Delay:     anim.Delay,
+			LoopCount: anim.LoopCount,
+			Disposal:  anim.Disposal,
+			Config:    anim.Config,
+		})
+		if err != nil {
+			return Image{}, err
+		}
+		frame = anim.Image[0]
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(dat))
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		frame = orient(img, exifOrientation(dat))
+		err = jpeg.Encode(&buf, frame, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return Image{}, err
+		}
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(dat))
+		if err != nil {
+			return Image{}, ErrUnsupportedType
+		}
+		frame = img
+		err = png.Encode(&buf, frame)
+		if err != nil {
+			return Image{}, err
+		}
+	}
+
+	processed.Data = buf.Bytes()
+	processed.Width = frame.Bounds().Dx()
+	processed.Height = frame.Bounds().Dy()
+
+	thumb := thumbnail(frame, ThumbSize)
+	processed.ThumbWidth = thumb.Bounds().Dx()
+	processed.ThumbHeight = thumb.Bounds().Dy()
+
+	buf = bytes.Buffer{}
+	if contentType == "image/jpeg" {
+		processed.ThumbContentType = "image/jpeg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		processed.ThumbContentType = "image/png"
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	processed.Thumb = buf.Bytes()
+
+	return processed, nil
+}
+
+// Ext is the file extension images of contentType are stored with, empty
+// for unsupported types
+func Ext(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	}
+	return ""
+}
+
+// Store keeps images in Dir by content hash, served under URL.
+//
+// Uploads hold the read lock from saving an image until it is recorded, and
+// garbage collection holds the write lock from picking unused images until
+// their files are deleted, so no upload is recorded against deleted files.
+type Store struct {
+	sync.RWMutex
+
+	Dir string
+	URL string
+}
+
+// Save writes an image and its thumbnail, returning their hash. Saving the
+// same image twice writes it once.
+func (s *Store) Save(img Image) (string, error) {
+	hash := img.Hash()
+
+	err := os.MkdirAll(filepath.Join(s.Dir, hash[:2]), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	files := map[string][]byte{
+		s.path(hash, Ext(img.ContentType), false):     img.Data,
+		s.path(hash, Ext(img.ThumbContentType), true): img.Thumb,
+	}
+	for path, dat := range files {
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		err = WriteFile(path, dat)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return hash, nil
+}
+
+// Remove deletes the files of an image
+func (s *Store) Remove(hash string, contentType string, thumbContentType string) error {
+	err := os.Remove(s.path(hash, Ext(contentType), false))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Remove(s.path(hash, Ext(thumbContentType), true))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// URLs returns where an image and its thumbnail are served
+func (s *Store) URLs(hash string, contentType string, thumbContentType string) (string, string) {
+	return s.URL + s.name(hash, Ext(contentType), false), s.URL + s.name(hash, Ext(thumbContentType), true)
+}
+
+// name is the path of a file relative to Dir, sharded by the hash prefix
+func (s *Store) name(hash string, ext string, thumb bool) string {
+	if thumb {
+		return hash[:2] + "/" + hash + ".thumb" + ext
+	}
+	return hash[:2] + "/" + hash + ext
+}
+
+func (s *Store) path(hash string, ext string, thumb bool) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(s.name(hash, ext, thumb)))
+}
+
+// WriteFile writes through a synced temporary file and rename, so readers
+// never see a partial image and concurrent writes of the same path don't
+// share a temporary file
+func WriteFile(path string, dat []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(dat)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	// Temporary files are private, images are served to everyone
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
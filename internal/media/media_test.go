@@ -0,0 +1,164 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessPNG(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	src.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	buf := bytes.Buffer{}
+	png.Encode(&buf, src)
+
+	img, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != 800 || img.Height != 400 || img.ContentType != "image/png" {
+		t.Errorf("unexpected image %dx%d %s", img.Width, img.Height, img.ContentType)
+	}
+	if img.ThumbWidth != ThumbSize || img.ThumbHeight != ThumbSize/2 {
+		t.Errorf("expected thumbnail to fit %d, got %dx%d", ThumbSize, img.ThumbWidth, img.ThumbHeight)
+	}
+
+	_, err = Process([]byte("plain text"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestProcessGIFLimits(t *testing.T) {
+	encode := func(frames int, size int) []byte {
+		anim := gif.GIF{}
+		for n := 0; n < frames; n++ {
+			anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, size, size), palette.Plan9))
+			anim.Delay = append(anim.Delay, 10)
+		}
+		buf := bytes.Buffer{}
+		gif.EncodeAll(&buf, &anim)
+		return buf.Bytes()
+	}
+
+	frames, pixels := gifFrames(encode(3, 10))
+	if frames != 3 || pixels != 300 {
+		t.Errorf("expected 3 frames of 300 pixels, got %d of %d", frames, pixels)
+	}
+
+	_, err := Process(encode(3, 10))
+	if err != nil {
+		t.Errorf("expected small GIF to pass, got %v", err)
+	}
+	_, err = Process(encode(MaxFrames+1, 1))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for too many frames, got %v", err)
+	}
+}
+
+func TestProcessStripsEXIF(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	buf := bytes.Buffer{}
+	jpeg.Encode(&buf, src, nil)
+	dat := withOrientation(buf.Bytes(), 6)
+	if exifOrientation(dat) != 6 {
+		t.Fatalf("expected orientation 6 in the test image")
+	}
+
+	img, err := Process(dat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotated upright, then written without the EXIF segment
+	if img.Width != 20 || img.Height != 40 {
+		t.Errorf("expected rotated 20x40, got %dx%d", img.Width, img.Height)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) {
+		t.Errorf("expected EXIF to be stripped")
+	}
+}
+
+func TestStoreConcurrentSaves(t *testing.T) {
+	s := Store{Dir: t.TempDir(), URL: "/media/"}
+	img := Image{Data: bytes.Repeat([]byte("full"), 1<<16), ContentType: "image/png", Thumb: []byte("thumb"), ThumbContentType: "image/png"}
+
+	// Uploads of the same image race to write the same files
+	errs := make(chan error, 8)
+	for n := 0; n < cap(errs); n++ {
+		go func() {
+			_, err := s.Save(img)
+			errs <- err
+		}()
+	}
+	for n := 0; n < cap(errs); n++ {
+		if err := <-errs; err != nil {
+			t.Errorf("expected concurrent saves to succeed, got %v", err)
+		}
+	}
+
+	hash := img.Hash()
+	dat, _ := os.ReadFile(s.path(hash, ".png", false))
+	if !bytes.Equal(dat, img.Data) {
+		t.Errorf("expected the whole image written, got %d bytes", len(dat))
+	}
+	tmps, _ := filepath.Glob(filepath.Join(s.Dir, hash[:2], "*.tmp*"))
+	if len(tmps) != 0 {
+		t.Errorf("expected no temporary files left, got %v", tmps)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := Store{Dir: t.TempDir(), URL: "/media/"}
+	img := Image{Data: []byte("full"), ContentType: "image/png", Thumb: []byte("thumb"), ThumbContentType: "image/png"}
+
+	hash, err := s.Save(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, thumb := s.URLs(hash, img.ContentType, img.ThumbContentType)
+	if url != "/media/"+hash[:2]+"/"+hash+".png" || thumb != "/media/"+hash[:2]+"/"+hash+".thumb.png" {
+		t.Errorf("unexpected urls %s %s", url, thumb)
+	}
+
+	dat, err := os.ReadFile(s.path(hash, ".png", false))
+	if err != nil || string(dat) != "full" {
+		t.Errorf("expected stored image, got %q %v", dat, err)
+	}
+
+	err = s.Remove(hash, img.ContentType, img.ThumbContentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(s.path(hash, ".png", true)); !os.IsNotExist(err) {
+		t.Errorf("expected thumbnail to be removed")
+	}
+}
+
+// withOrientation inserts an EXIF segment holding only an orientation tag
+// after the start of a JPEG
+func withOrientation(dat []byte, orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3)
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	out := append([]byte{}, dat[:2]...)
+	out = append(out, 0xFF, 0xE1)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(segment)+2))
+	out = append(out, segment...)
+	return append(out, dat[2:]...)
+}
@@ -1,6 +1,30 @@
 package webserver
 
+import (
+	"time"
+
+	"github.com/Quorum-Code/chirpy/internal/database"
+)
+
 type ServerConfig struct {
 	IsDebug   bool
 	IsTesting bool
+
+	// Storage backend, database.StorageJSON (default) or database.StorageSQLite
+	Storage string
+
+	// Reload the JSON database file when it is edited outside the server
+	WatchDatabase bool
+
+	// Chirpy Red plans and the Polka events that move users between them,
+	// nil for database.DefaultPlanConfig
+	Plans *database.PlanConfig
+
+	// How long after posting a chirp can be edited on the free plan,
+	// overriding the free plan of Plans, 0 to leave it to the plans. Other
+	// plans keep their own edit window.
+	EditWindow time.Duration
+
+	// How long deleted chirps can be restored before they are purged
+	TrashRetention time.Duration
 }
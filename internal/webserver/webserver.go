@@ -6,10 +6,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Quorum-Code/chirpy/internal"
 	"github.com/Quorum-Code/chirpy/internal/database"
 	"github.com/Quorum-Code/chirpy/internal/endpoints"
+	"github.com/Quorum-Code/chirpy/internal/media"
 
 	"github.com/flowchartsman/swaggerui"
 	"github.com/joho/godotenv"
@@ -20,11 +23,30 @@ var spec []byte
 var root = "../../."
 
 var ChirpyFolder = ".chirpy"
+var AvatarFolder = "avatars"
+var MediaFolder = "media"
 var DatabaseFile = "database.json"
+var SQLiteDatabaseFile = "database.sqlite"
 var TestingDatabaseFile = "database-testing.json"
 var TestingDatabasePath = "./test/data/database-testing.json"
 
-func StartServer(cfg ServerConfig) *http.Server {
+// How often a watched database file is checked for external edits
+var WatchInterval = 2 * time.Second
+
+// How often expired chirps are purged from the trash
+var PurgeInterval = time.Hour
+
+// How often scheduled chirps are checked for publishing
+var ScheduleInterval = 15 * time.Second
+
+// How long an upload can wait to be attached to a chirp before it is
+// collected
+var MediaGrace = 24 * time.Hour
+
+// StartServer starts serving in the background. After shutting the server
+// down, the caller runs the returned close func to stop background jobs and
+// flush the database.
+func StartServer(cfg ServerConfig) (*http.Server, func() error) {
 	fmt.Println("starting web server")
 
 	// Load env variables
@@ -32,19 +54,26 @@ func StartServer(cfg ServerConfig) *http.Server {
 
 	// Create server
 	mux := http.NewServeMux()
-	fileServer := http.FileServer(http.Dir(root))
-	apiCfg := endpoints.ApiConfig{}
+	fileServer := http.FileServer(hideUploadDirs{http.Dir(root), "/" + ChirpyFolder})
+	apiCfg := endpoints.ApiConfig{
+		AvatarDir: filepath.Join(root, ChirpyFolder, AvatarFolder),
+		AvatarURL: "/app/" + ChirpyFolder + "/" + AvatarFolder + "/",
+		Media: &media.Store{
+			Dir: filepath.Join(root, ChirpyFolder, MediaFolder),
+			URL: "/app/" + ChirpyFolder + "/" + MediaFolder + "/",
+		},
+	}
 
 	if cfg.IsDebug {
 		// Load empty database
-		apiCfg.Db = *database.InitCleanDB()
+		apiCfg.Db = database.InitCleanDB()
 	} else {
 		var path string
 		if cfg.IsTesting {
 			cwd, err := os.Getwd()
 			if err != nil {
 				fmt.Printf("%s\n", err.Error())
-				return nil
+				return nil, nil
 			}
 
 			path = filepath.Join(cwd, TestingDatabaseFile)
@@ -53,29 +82,66 @@ func StartServer(cfg ServerConfig) *http.Server {
 			home, err := os.UserHomeDir()
 			if err != nil {
 				fmt.Println(err.Error())
-				return nil
+				return nil, nil
 			}
 
 			// join path
-			path = filepath.Join(home, ChirpyFolder, DatabaseFile)
+			if cfg.Storage == database.StorageSQLite {
+				path = filepath.Join(home, ChirpyFolder, SQLiteDatabaseFile)
+			} else {
+				path = filepath.Join(home, ChirpyFolder, DatabaseFile)
+			}
 		}
 
-		// Get database file
-		reader, err := os.Open(path)
-		if err != nil {
-			dir, _ := os.Getwd()
+		if cfg.Storage == database.StorageSQLite {
+			// SQLite creates the file if missing
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			if err != nil {
+				fmt.Println(err.Error())
+				return nil, nil
+			}
+		} else {
+			// Get database file
+			reader, err := os.Open(path)
+			if err != nil {
+				dir, _ := os.Getwd()
 
-			fmt.Printf("%s, couldn't open db file, %s", err.Error(), dir)
-			return nil
+				fmt.Printf("%s, couldn't open db file, %s", err.Error(), dir)
+				return nil, nil
+			}
+			reader.Close()
 		}
 
 		// Initialize database
-		db, err := database.InitDB(reader, path)
+		db, err := database.InitStorageDB(cfg.Storage, path)
+		if err != nil {
+			fmt.Println(err.Error())
+			return nil, nil
+		}
+		apiCfg.Db = db
+
+		if cfg.WatchDatabase {
+			err = db.Watch(WatchInterval)
+			if err != nil {
+				fmt.Println(err.Error())
+				return nil, nil
+			}
+			fmt.Printf("watching %s for external edits\n", path)
+		}
+	}
+
+	if cfg.Plans != nil {
+		err := apiCfg.Db.SetPlanConfig(*cfg.Plans)
 		if err != nil {
 			fmt.Println(err.Error())
-			return nil
+			return nil, nil
 		}
-		apiCfg.Db = *db
+	}
+	if cfg.EditWindow > 0 {
+		apiCfg.Db.SetEditWindow(cfg.EditWindow)
+	}
+	if cfg.TrashRetention > 0 {
+		apiCfg.Db.SetTrashRetention(cfg.TrashRetention)
 	}
 
 	// Index url handler
@@ -105,9 +171,58 @@ func StartServer(cfg ServerConfig) *http.Server {
 	mux.HandleFunc("POST /api/chirps", apiCfg.PostChirpsHandler)
 	mux.HandleFunc("GET /api/chirps", apiCfg.GetChirpsHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.GetChirpByIDHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.GetRevisionsHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/revisions/{revision}/restore", apiCfg.PostRestoreRevisionHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.PostRestoreChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.GetThreadHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.PostLikeHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.DeleteLikeHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.PostRechirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.DeleteRechirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/vote", apiCfg.PostVoteHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.PostBookmarkHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.DeleteBookmarkHandler)
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.GetBookmarksHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", apiCfg.PostPinHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.DeletePinHandler)
+	mux.HandleFunc("GET /api/trash", apiCfg.GetTrashHandler)
 	mux.HandleFunc("POST /api/users", apiCfg.PostUserHandler)
 	mux.HandleFunc("POST /api/login", apiCfg.PostLoginHandler)
 	mux.HandleFunc("PUT /api/users", apiCfg.PutUsersHandler)
+	mux.HandleFunc("GET /api/users/{handle}", apiCfg.GetUserHandler)
+	mux.HandleFunc("PUT /api/profile", apiCfg.PutProfileHandler)
+	mux.HandleFunc("POST /api/profile/avatar", apiCfg.PostAvatarHandler)
+	mux.HandleFunc("GET /api/drafts", apiCfg.GetDraftsHandler)
+	mux.HandleFunc("POST /api/drafts", apiCfg.PostDraftHandler)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", apiCfg.PutDraftHandler)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", apiCfg.DeleteDraftHandler)
+	mux.HandleFunc("POST /api/media", apiCfg.PostMediaHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}", apiCfg.GetMediaHandler)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.PostFollowHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.DeleteFollowHandler)
+	mux.HandleFunc("POST /api/users/{userID}/block", apiCfg.PostBlockHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.DeleteBlockHandler)
+	mux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.PostMuteHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.DeleteMuteHandler)
+	mux.HandleFunc("GET /api/blocks", apiCfg.GetBlocksHandler)
+	mux.HandleFunc("GET /api/mutes", apiCfg.GetMutesHandler)
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.GetFollowersHandler)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.GetFollowingHandler)
+	mux.HandleFunc("GET /api/timeline", apiCfg.GetTimelineHandler)
+	mux.HandleFunc("GET /api/entitlements", apiCfg.GetEntitlementsHandler)
+	mux.HandleFunc("POST /api/lists", apiCfg.PostListHandler)
+	mux.HandleFunc("GET /api/users/{userID}/lists", apiCfg.GetUserListsHandler)
+	mux.HandleFunc("GET /api/lists/{listID}", apiCfg.GetListHandler)
+	mux.HandleFunc("PUT /api/lists/{listID}", apiCfg.PutListHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}", apiCfg.DeleteListHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/members", apiCfg.GetListMembersHandler)
+	mux.HandleFunc("POST /api/lists/{listID}/members/{userID}", apiCfg.PostListMemberHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", apiCfg.DeleteListMemberHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/chirps", apiCfg.GetListTimelineHandler)
+	mux.HandleFunc("GET /api/users/{userID}/mentions", apiCfg.GetMentionsHandler)
+	mux.HandleFunc("GET /api/tags/trending", apiCfg.GetTrendingTagsHandler)
+	mux.HandleFunc("GET /api/tags/{tag}/chirps", apiCfg.GetTagChirpsHandler)
+	mux.HandleFunc("GET /api/search/chirps", apiCfg.GetSearchChirpsHandler)
 	mux.HandleFunc("POST /api/refresh", apiCfg.PostRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.PostRevoke)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.DeleteChirpsHandler)
@@ -123,6 +238,13 @@ func StartServer(cfg ServerConfig) *http.Server {
 	// Final server setup
 	corsMux := internal.MiddlewareCors(mux)
 	server := http.Server{Addr: ":8000", Handler: corsMux}
+	stopPurge := startPurge(apiCfg.Db, apiCfg.Media, PurgeInterval)
+	stopScheduler := startScheduler(apiCfg.Db, ScheduleInterval)
+	closeServer := func() error {
+		stopPurge()
+		stopScheduler()
+		return apiCfg.Db.Close()
+	}
 
 	// Start server
 	go func() {
@@ -132,7 +254,110 @@ func StartServer(cfg ServerConfig) *http.Server {
 		}
 	}()
 
-	return &server
+	return &server, closeServer
+}
+
+// hideUploadDirs keeps the file server from listing the directories under
+// prefix, so uploads are only found through the chirps that show them
+type hideUploadDirs struct {
+	fs     http.FileSystem
+	prefix string
+}
+
+func (h hideUploadDirs) Open(name string) (http.File, error) {
+	f, err := h.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if name != h.prefix && !strings.HasPrefix(name, h.prefix+"/") {
+		return f, nil
+	}
+
+	stat, err := f.Stat()
+	if err != nil || stat.IsDir() {
+		f.Close()
+		return nil, os.ErrNotExist
+	}
+	return f, nil
+}
+
+// startPurge removes expired chirps from the trash, then media no chirp
+// shows, every interval until the returned func is called. The func returns
+// once a purge in progress has finished.
+func startPurge(db *database.DB, store *media.Store, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				n, err := db.PurgeExpired()
+				if err != nil {
+					fmt.Printf("ERROR: %s\n", err)
+				} else if n > 0 {
+					fmt.Printf("purged %d expired chirps\n", n)
+				}
+
+				store.Lock()
+				collected, err := db.CollectMedia(MediaGrace)
+				if err != nil {
+					fmt.Printf("ERROR: %s\n", err)
+				}
+				for _, m := range collected {
+					err = store.Remove(m.Hash, m.ContentType, m.ThumbnailContentType)
+					if err != nil {
+						fmt.Printf("ERROR: %s\n", err)
+					}
+				}
+				store.Unlock()
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// startScheduler publishes scheduled chirps as they come due, checking every
+// interval until the returned channel is closed. Chirps that came due while
+// the server was down are published on the first check. Like startPurge it
+// returns a func that stops it.
+func startScheduler(db *database.DB, interval time.Duration) func() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			published, err := db.PublishDue()
+			if err != nil {
+				fmt.Printf("ERROR: %s\n", err)
+			} else if len(published) > 0 {
+				fmt.Printf("published %d scheduled chirps\n", len(published))
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
 }
 
 // func getServerSpecs() ([]byte, error) {
@@ -1,15 +1,54 @@
 package webserver
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 func TestServer(t *testing.T) {
-	server := StartServer(ServerConfig{
+	server, closeServer := StartServer(ServerConfig{
 		IsTesting: true,
 	})
 
 	if server == nil {
-		t.Error("server failed to start")
+		t.Fatal("server failed to start")
+	}
+
+	server.Close()
+	err := closeServer()
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHideUploadDirs(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, ".chirpy", "media", "aa"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, ".chirpy", "media", "aa", "aa.png"), []byte("png"), 0644)
+	os.WriteFile(filepath.Join(dir, "index.html"), []byte("hi"), 0644)
+	server := http.FileServer(hideUploadDirs{http.Dir(dir), "/.chirpy"})
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/.chirpy/media/aa/aa.png", http.StatusOK},
+		{"/.chirpy/media/aa/", http.StatusNotFound},
+		{"/.chirpy/media/", http.StatusNotFound},
+		{"/.chirpy/", http.StatusNotFound},
+		{"/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.code, rec.Code)
+		}
 	}
 }